@@ -1,18 +1,18 @@
 package main
 
 import (
-	"bytes"
-	"container/list"
 	"fmt"
 	directorywalk "grep-go/internal/directoryWalk"
 	"grep-go/internal/fileSearch"
 	"grep-go/internal/matcher"
-	"io"
+	"grep-go/internal/parsers"
+	"grep-go/internal/printer"
 	"os"
+	"runtime"
 )
 
-// Blank import to satisfy linter - bytes.ContainsAny is available but not used
-var _ = bytes.ContainsAny
+// usage is printed to stderr whenever the command line cannot be parsed.
+const usage = "usage: %s [-G | -E | -P | -F] [-r | -R] [-H | -h] [-n] [-b] [-o] [-v] [-w] [-x] [-c | -l | -L | -q]\n       [-A num] [-B num] [-C num] [-m num]\n       [--color[=WHEN]] [--json] [-Z] [-z] [--leftmost-longest]\n       [-j num] [--no-sort] [-s] [--hidden] [--no-ignore]\n       [--include=GLOB] [--exclude=GLOB] [--exclude-dir=GLOB]\n       [-t type] [-T type] [--type-add name:glob] [--type-list]\n       [-a | -I | --binary-files=TYPE]\n       [--max-line-length=NUM] [--long-lines=POLICY]\n       (<pattern> | -e <pattern>... | -f <file>...) [files...]\n"
 
 // main is the entry point for the toy_grep application.
 // It handles command line arguments and routes to appropriate search functions.
@@ -22,109 +22,111 @@ var _ = bytes.ContainsAny
 //   - toy_grep -E "pattern" file.txt               (single file search)
 //   - toy_grep -E "pattern" file1.txt file2.txt    (multiple file search)
 //   - toy_grep -r -E "pattern" directory/          (recursive directory search)
+//   - toy_grep -e "one" -e "two" -f patterns.txt   (several patterns, any may match)
 //
 // Exit codes:
-//   - 0: Pattern matched successfully
-//   - 1: No match found
+//   - 0: At least one line was selected (matched, or did not match with -v)
+//   - 1: No line was selected
 //   - 2: Error in execution (invalid args, IO error, parse error, etc.)
 func main() {
-	// Validate minimum argument count
-	// At minimum, we need the program name + one flag
-	if len(os.Args) < 2 {
+	// Parse command line arguments (without the program name)
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
 		os.Exit(2) // Exit with error code for invalid usage
 	}
 
-	var pattern string // The regex pattern to search for
-	var ok bool        // Whether the pattern matched
-	var err error      // Any error that occurred during processing
-
-	// Parse command line arguments and route to appropriate handler
-	switch os.Args[1] {
-	case "-r":
-		// Recursive directory search mode
-		// Expected format: toy_grep -r -E "pattern" directory/
-		// Args: [program_name, "-r", "-E", "pattern", "directory"]
-		//       [0]           [1]   [2]   [3]        [4]
-
-		if len(os.Args) < 5 {
-			fmt.Fprintf(os.Stderr, "usage: %s -r -E <pattern> <directory>\n", os.Args[0])
+	if opts.typeList {
+		if err := opts.types.List(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(2)
 		}
+		os.Exit(0)
+	}
 
-		pattern = os.Args[3] // Extract pattern from args
-		// Recursively search directory and all subdirectories
-		ok, err = directorywalk.DirectorySearch(os.Args[4], pattern)
-
-	case "-E":
-		// Extended regex mode (similar to grep -E)
-		// Multiple sub-modes based on additional arguments
-
-		if len(os.Args) < 3 {
-			fmt.Fprintf(os.Stderr, "usage: %s -E <pattern> [files...]\n", os.Args[0])
-			os.Exit(2)
-		}
-
-		pattern = os.Args[2] // Extract pattern from args
-
-		if len(os.Args) == 4 {
-			// Single file search mode
-			// Expected format: toy_grep -E "pattern" file.txt
-
-			var matches *list.List // List of matching lines
-			var file *os.File      // File handle
-
-			// Open the specified file for reading
-			file, err = os.Open(os.Args[3])
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "File IO error: %v\n", err)
-				os.Exit(2)
-			}
-			defer file.Close() // Ensure file is closed when function exits
-
-			// Search for pattern in the single file
-			ok, matches, err = fileSearch.SingleFileSearch(file, pattern)
-
-			// If no matches found, exit with code 1
-			if !ok {
-				os.Exit(1)
-			}
-
-			// Print all matching lines to stdout
-			for line := matches.Front(); line != nil; line = line.Next() {
-				stringValue := line.Value.(string)
-				fmt.Println(stringValue)
-			}
-
-		} else if len(os.Args) > 4 {
-			// Multiple file search mode
-			// Expected format: toy_grep -E "pattern" file1.txt file2.txt file3.txt
+	// Parse the patterns once, up front, into a single matcher used for
+	// every file and line
+	m, err := compile(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
 
-			// Pass slice of filenames (excluding program name, -E, and pattern)
-			// os.Args[3:] contains all the file names
-			ok, err = fileSearch.FileSearch(os.Args[3:], pattern)
+	if opts.jobs == 0 {
+		opts.jobs = runtime.GOMAXPROCS(0)
+	}
 
+	files := opts.files
+	if len(files) == 0 {
+		if opts.recursive {
+			// Recursive search without operands searches the working directory
+			files = []string{"."}
 		} else {
 			// Standard input (stdin) search mode
 			// Expected format: echo "text" | toy_grep -E "pattern"
+			files = []string{fileSearch.StdinName}
+		}
+	}
 
-			var line []byte
+	// A single printer handles the output of every search mode
+	var out printer.Output
+	if opts.json {
+		out = printer.NewJSON(os.Stdout)
+	} else {
+		out = printer.New(os.Stdout, printer.Options{
+			WithFilename:  withFilename(opts, files),
+			LineNumber:    opts.lineNum,
+			ByteOffset:    opts.byteOff,
+			OnlyMatching:  opts.onlyMatching,
+			Context:       opts.before > 0 || opts.after > 0,
+			Colors:        colors(opts.color),
+			NullAfterName: opts.nullAfterName,
+			NullData:      opts.nullData,
+		})
+	}
 
-			// Read all input from stdin
-			line, err = io.ReadAll(os.Stdin)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error: read input text: %v\n", err)
-				os.Exit(2)
-			}
+	searchOpts := fileSearch.Options{
+		Mode:          opts.mode,
+		Spans:         opts.onlyMatching || opts.color != "never" || opts.json,
+		Invert:        opts.invert,
+		Before:        opts.before,
+		After:         opts.after,
+		MaxCount:      opts.maxCount,
+		NullData:      opts.nullData,
+		Binary:        opts.binary,
+		MaxLineLength: opts.maxLineLength,
+		LongLines:     opts.longLines,
+		Workers:       opts.jobs,
+		Unordered:     opts.noSort,
+		Errors:        fileSearch.NewErrorLog(opts.noMessages),
+	}
 
-			// Match pattern against stdin input
-			ok, err = matcher.MatchPattern(line, pattern)
-		}
+	walkOpts := directorywalk.Options{
+		Hidden:       opts.hidden,
+		NoIgnore:     opts.noIgnore,
+		Files:        opts.fileGlobs,
+		ExcludeDirs:  opts.excludeDirs,
+		TypeGlobs:    opts.typeGlobs,
+		NotTypeGlobs: opts.notTypeGlobs,
+		Follow:       opts.follow,
+	}
 
-	default:
-		// Invalid flag provided
-		fmt.Fprintf(os.Stderr, "error: unsupported flag %s\n", os.Args[1])
-		fmt.Fprintf(os.Stderr, "usage: %s [-E <pattern>] [-r -E <pattern> <directory>]\n", os.Args[0])
-		os.Exit(2)
+	var ok bool // Whether the pattern matched
+
+	if opts.recursive {
+		// Recursive directory search mode
+		// Expected format: toy_grep -r -E "pattern" directory/
+		ok, err = directorywalk.DirectorySearch(files, walkOpts, m, searchOpts, out)
+	} else {
+		// Stdin, single file and multiple file search modes
+		// Expected format: toy_grep -E "pattern" file1.txt file2.txt
+		files = directorywalk.FilterOperands(files, walkOpts)
+		ok, err = fileSearch.FileSearch(files, m, searchOpts, out)
+	}
+
+	if flushErr := out.Finish(); err == nil {
+		err = flushErr
 	}
 
 	// Handle any errors that occurred during processing
@@ -133,13 +135,74 @@ func main() {
 		os.Exit(2)
 	}
 
-	// Handle case where no matches were found
+	// Handle case where no matches were found. Files or directories
+	// that could not be searched make it an error, as they might have
+	// matched
 	if !ok {
-		// Uncomment for debugging: fmt.Println("Error, exit with 1")
+		if searchOpts.Errors.Failed() {
+			os.Exit(2)
+		}
 		os.Exit(1) // Exit code 1 indicates no matches found
 	}
 
 	// Success case - pattern matched
-	// Uncomment for debugging: fmt.Println("Successful match")
 	os.Exit(0) // Exit code 0 indicates successful match
 }
+
+// compile builds the matcher for the patterns in the selected syntax.
+func compile(opts *options) (matcher.Matcher, error) {
+	matchOpts := matcher.Options{WordMatch: opts.wordMatch, LineMatch: opts.lineMatch}
+	if opts.longest {
+		matchOpts.Semantics = matcher.LeftmostLongest
+	}
+
+	switch opts.syntax {
+	case syntaxFixed:
+		// Fixed strings never go through the regex parser
+		return matcher.CompileFixed(opts.patterns, matchOpts), nil
+	case syntaxExtended:
+		matchOpts.Syntax = parsers.SyntaxExtended
+	case syntaxPerl:
+		matchOpts.Syntax = parsers.SyntaxPerl
+	default:
+		matchOpts.Syntax = parsers.SyntaxBasic
+	}
+	return matcher.CompileAll(opts.patterns, matchOpts)
+}
+
+// withFilename decides whether output lines are prefixed with their file
+// name. -H and -h always win; otherwise the name is shown only when more
+// than one file can be searched, i.e. several operands were given or a
+// directory is searched recursively.
+func withFilename(opts *options, files []string) bool {
+	switch opts.filename {
+	case filenameAlways:
+		return true
+	case filenameNever:
+		return false
+	}
+
+	if len(files) > 1 {
+		return true
+	}
+
+	if opts.recursive {
+		info, err := os.Stat(files[0])
+		return err == nil && info.IsDir()
+	}
+
+	return false
+}
+
+// colors resolves the --color setting: "auto" colors the output only
+// when stdout is a terminal. The colors come from GREP_COLORS.
+//
+// Returns:
+//   - *printer.Colors: the colors to use, nil to disable colors
+func colors(when string) *printer.Colors {
+	if when == "never" || (when == "auto" && !printer.IsTerminal(os.Stdout)) {
+		return nil
+	}
+	colors := printer.ParseColors(os.Getenv("GREP_COLORS"))
+	return &colors
+}
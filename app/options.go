@@ -0,0 +1,555 @@
+package main
+
+import (
+	"fmt"
+	directorywalk "grep-go/internal/directoryWalk"
+	"grep-go/internal/fileSearch"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// filenameMode records whether -H or -h was given on the command line.
+// When neither was given, the prefix is decided by how many files are searched.
+type filenameMode int
+
+const (
+	filenameAuto   filenameMode = iota // prefix only when searching more than one file
+	filenameAlways                     // -H, --with-filename
+	filenameNever                      // -h, --no-filename
+)
+
+// syntax is the way patterns are interpreted.
+type syntax int
+
+const (
+	syntaxBasic    syntax = iota // -G: basic regular expressions, the default
+	syntaxExtended               // -E: extended regular expressions
+	syntaxPerl                   // -P: Perl-compatible regular expressions
+	syntaxFixed                  // -F: fixed strings
+)
+
+// options holds everything parsed from the command line.
+type options struct {
+	patterns  []string     // The regex patterns to search for, any of them may match
+	files     []string     // File or directory operands, in order
+	syntax    syntax       // -G / -E / -P / -F: how patterns are interpreted
+	recursive bool         // -r: descend into directories
+	follow    bool         // -R: also follow symbolic links inside directories
+	filename  filenameMode // -H / -h handling
+	lineNum   bool         // -n: print line numbers
+	byteOff   bool         // -b: print byte offsets
+
+	onlyMatching bool // -o: print only the matched parts of lines
+	invert       bool // -v: select non-matching lines
+	wordMatch    bool // -w: matches must be whole words
+	lineMatch    bool // -x: matches must be whole lines
+	longest      bool // --leftmost-longest: POSIX match semantics
+
+	mode fileSearch.Mode // -c, -l, -L, -q: what to report per file
+
+	// Context line counts; -1 when not given. -A and -B take precedence
+	// over -C regardless of their order.
+	before  int // -B
+	after   int // -A
+	context int // -C
+
+	maxCount int // -m: stop after this many selected lines, -1 for no limit
+
+	color string // --color: "always", "never" or "auto"
+	json  bool   // --json: write JSON Lines instead of text
+
+	nullAfterName bool // -Z: end file names with NUL
+	nullData      bool // -z: NUL terminated input and output records
+
+	patternGiven bool // whether -e or -f was used, making every operand a file
+
+	jobs   int  // -j: number of files searched concurrently, 0 for GOMAXPROCS
+	noSort bool // --no-sort: print results as files finish, not in walk order
+
+	noMessages bool // -s: do not report unreadable files and directories
+
+	binary fileSearch.BinaryMode // --binary-files, -a, -I: how binary files are searched
+
+	maxLineLength int                       // --max-line-length: longest line searched, 0 for no limit
+	longLines     fileSearch.LongLinePolicy // --long-lines: what to do with longer lines
+
+	hidden   bool // --hidden: also search hidden files and directories
+	noIgnore bool // --no-ignore: do not honor ignore files
+
+	fileGlobs   []directorywalk.FileGlob // --include and --exclude, in order
+	excludeDirs []string                 // --exclude-dir
+
+	types        directorywalk.Types // file type registry, extended by --type-add
+	typeNames    []string            // -t: search only files of these types
+	notTypeNames []string            // -T: skip files of these types
+	typeList     bool                // --type-list: print the registry and exit
+
+	typeGlobs    []string // globs of the -t types
+	notTypeGlobs []string // globs of the -T types
+}
+
+// flagSpec describes a single command line flag.
+//
+// Fields:
+//   - hasArg:      whether the flag consumes a value (-m 3, --max-count=3)
+//   - optionalArg: long flag whose value may be left out (--color, --color=always)
+//   - apply:       stores the flag (and its value, if any) into the options
+type flagSpec struct {
+	hasArg      bool
+	optionalArg bool
+	apply       func(opts *options, value string) error
+}
+
+// shortFlags maps single letter flags onto their long names.
+var shortFlags = map[byte]string{
+	'G': "basic-regexp",
+	'E': "extended-regexp",
+	'P': "perl-regexp",
+	'F': "fixed-strings",
+	'r': "recursive",
+	'R': "dereference-recursive",
+	'H': "with-filename",
+	'h': "no-filename",
+	'n': "line-number",
+	'b': "byte-offset",
+	'o': "only-matching",
+	'v': "invert-match",
+	'w': "word-regexp",
+	'x': "line-regexp",
+	'c': "count",
+	'l': "files-with-matches",
+	'L': "files-without-match",
+	'q': "quiet",
+	'A': "after-context",
+	'B': "before-context",
+	'C': "context",
+	'm': "max-count",
+	'Z': "null",
+	'z': "null-data",
+	'e': "regexp",
+	'f': "file",
+	'j': "threads",
+	's': "no-messages",
+	'a': "text",
+	'I': "ignore-binary",
+	't': "type",
+	'T': "type-not",
+}
+
+// longFlags holds the definition of every supported flag, keyed by long name.
+var longFlags = map[string]flagSpec{
+	"basic-regexp": {apply: func(opts *options, _ string) error {
+		opts.syntax = syntaxBasic
+		return nil
+	}},
+	"extended-regexp": {apply: func(opts *options, _ string) error {
+		opts.syntax = syntaxExtended
+		return nil
+	}},
+	"perl-regexp": {apply: func(opts *options, _ string) error {
+		opts.syntax = syntaxPerl
+		return nil
+	}},
+	"fixed-strings": {apply: func(opts *options, _ string) error {
+		opts.syntax = syntaxFixed
+		return nil
+	}},
+	"recursive": {apply: func(opts *options, _ string) error {
+		opts.recursive = true
+		return nil
+	}},
+	"dereference-recursive": followFlag,
+	"follow":                followFlag,
+	"with-filename": {apply: func(opts *options, _ string) error {
+		opts.filename = filenameAlways
+		return nil
+	}},
+	"no-filename": {apply: func(opts *options, _ string) error {
+		opts.filename = filenameNever
+		return nil
+	}},
+	"line-number": {apply: func(opts *options, _ string) error {
+		opts.lineNum = true
+		return nil
+	}},
+	"byte-offset": {apply: func(opts *options, _ string) error {
+		opts.byteOff = true
+		return nil
+	}},
+	"only-matching": {apply: func(opts *options, _ string) error {
+		opts.onlyMatching = true
+		return nil
+	}},
+	"invert-match": {apply: func(opts *options, _ string) error {
+		opts.invert = true
+		return nil
+	}},
+	"word-regexp": {apply: func(opts *options, _ string) error {
+		opts.wordMatch = true
+		return nil
+	}},
+	"line-regexp": {apply: func(opts *options, _ string) error {
+		opts.lineMatch = true
+		return nil
+	}},
+	"leftmost-longest": {apply: func(opts *options, _ string) error {
+		opts.longest = true
+		return nil
+	}},
+	"count":               modeFlag(fileSearch.ModeCount),
+	"files-with-matches":  modeFlag(fileSearch.ModeFilesWithMatch),
+	"files-without-match": modeFlag(fileSearch.ModeFilesWithoutMatch),
+	"quiet":               modeFlag(fileSearch.ModeQuiet),
+	"silent":              modeFlag(fileSearch.ModeQuiet),
+	"after-context": {hasArg: true, apply: func(opts *options, value string) error {
+		return parseContext(&opts.after, value)
+	}},
+	"before-context": {hasArg: true, apply: func(opts *options, value string) error {
+		return parseContext(&opts.before, value)
+	}},
+	"context": {hasArg: true, apply: func(opts *options, value string) error {
+		return parseContext(&opts.context, value)
+	}},
+	"max-count": {hasArg: true, apply: func(opts *options, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid max count")
+		}
+		// GNU grep treats a negative count as no limit
+		opts.maxCount = max(n, -1)
+		return nil
+	}},
+	"json": {apply: func(opts *options, _ string) error {
+		opts.json = true
+		return nil
+	}},
+	"null": {apply: func(opts *options, _ string) error {
+		opts.nullAfterName = true
+		return nil
+	}},
+	"null-data": {apply: func(opts *options, _ string) error {
+		opts.nullData = true
+		return nil
+	}},
+	"regexp": {hasArg: true, apply: func(opts *options, value string) error {
+		opts.patterns = append(opts.patterns, splitPatterns(value)...)
+		opts.patternGiven = true
+		return nil
+	}},
+	"threads": {hasArg: true, apply: func(opts *options, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s: invalid number of threads", value)
+		}
+		opts.jobs = n
+		return nil
+	}},
+	"no-sort": {apply: func(opts *options, _ string) error {
+		opts.noSort = true
+		return nil
+	}},
+	"no-messages": {apply: func(opts *options, _ string) error {
+		opts.noMessages = true
+		return nil
+	}},
+	"hidden": {apply: func(opts *options, _ string) error {
+		opts.hidden = true
+		return nil
+	}},
+	"no-ignore": {apply: func(opts *options, _ string) error {
+		opts.noIgnore = true
+		return nil
+	}},
+	"include": {hasArg: true, apply: func(opts *options, value string) error {
+		opts.fileGlobs = append(opts.fileGlobs, directorywalk.FileGlob{Pattern: value, Include: true})
+		return nil
+	}},
+	"exclude": {hasArg: true, apply: func(opts *options, value string) error {
+		opts.fileGlobs = append(opts.fileGlobs, directorywalk.FileGlob{Pattern: value})
+		return nil
+	}},
+	"exclude-dir": {hasArg: true, apply: func(opts *options, value string) error {
+		opts.excludeDirs = append(opts.excludeDirs, value)
+		return nil
+	}},
+	"type": {hasArg: true, apply: func(opts *options, value string) error {
+		opts.typeNames = append(opts.typeNames, value)
+		return nil
+	}},
+	"type-not": {hasArg: true, apply: func(opts *options, value string) error {
+		opts.notTypeNames = append(opts.notTypeNames, value)
+		return nil
+	}},
+	"type-add": {hasArg: true, apply: func(opts *options, value string) error {
+		return opts.types.Add(value)
+	}},
+	"type-list": {apply: func(opts *options, _ string) error {
+		opts.typeList = true
+		return nil
+	}},
+	"text": {apply: func(opts *options, _ string) error {
+		opts.binary = fileSearch.BinaryText
+		return nil
+	}},
+	"ignore-binary": {apply: func(opts *options, _ string) error {
+		opts.binary = fileSearch.BinarySkip
+		return nil
+	}},
+	"binary-files":    {hasArg: true, apply: parseBinaryFiles},
+	"max-line-length": {hasArg: true, apply: parseMaxLineLength},
+	"long-lines":      {hasArg: true, apply: parseLongLines},
+	"file":            {hasArg: true, apply: readPatternFile},
+	"color":           {optionalArg: true, apply: parseColor},
+	"colour":          {optionalArg: true, apply: parseColor},
+}
+
+// followFlag is -R: a recursive search following every symbolic link.
+var followFlag = flagSpec{apply: func(opts *options, _ string) error {
+	opts.recursive = true
+	opts.follow = true
+	return nil
+}}
+
+// splitPatterns splits a pattern argument on newlines: like GNU grep,
+// every line of it is a separate pattern.
+func splitPatterns(value string) []string {
+	return strings.Split(value, "\n")
+}
+
+// readPatternFile reads the patterns of -f FILE, one per line ("-" reads
+// standard input). An empty line is an empty pattern, which matches
+// every line; an empty file adds no pattern at all.
+func readPatternFile(opts *options, name string) error {
+	var data []byte
+	var err error
+	if name == fileSearch.StdinName {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return fmt.Errorf("pattern file: %w", err)
+	}
+
+	opts.patternGiven = true
+	text := strings.TrimSuffix(string(data), "\n")
+	if len(data) > 0 {
+		opts.patterns = append(opts.patterns, splitPatterns(text)...)
+	}
+	return nil
+}
+
+// parseColor parses the WHEN argument of --color, accepting the same
+// synonyms as GNU grep. A bare --color means auto.
+func parseColor(opts *options, value string) error {
+	switch value {
+	case "", "auto", "tty", "if-tty":
+		opts.color = "auto"
+	case "always", "yes", "force":
+		opts.color = "always"
+	case "never", "no", "none":
+		opts.color = "never"
+	default:
+		return fmt.Errorf("invalid argument '%s' for '--color'", value)
+	}
+	return nil
+}
+
+// parseBinaryFiles parses the TYPE argument of --binary-files.
+func parseBinaryFiles(opts *options, value string) error {
+	switch value {
+	case "binary":
+		opts.binary = fileSearch.BinaryMatch
+	case "text":
+		opts.binary = fileSearch.BinaryText
+	case "without-match":
+		opts.binary = fileSearch.BinarySkip
+	default:
+		return fmt.Errorf("invalid argument '%s' for '--binary-files'", value)
+	}
+	return nil
+}
+
+// parseLongLines parses the POLICY argument of --long-lines.
+func parseLongLines(opts *options, value string) error {
+	switch value {
+	case "warn":
+		opts.longLines = fileSearch.LongLinesWarn
+	case "skip":
+		opts.longLines = fileSearch.LongLinesSkip
+	default:
+		return fmt.Errorf("invalid argument '%s' for '--long-lines'", value)
+	}
+	return nil
+}
+
+// parseMaxLineLength parses the size of --max-line-length: a number of
+// bytes, optionally followed by a K, M or G multiplier (64K, 1M).
+func parseMaxLineLength(opts *options, value string) error {
+	multiplier := 1
+	number := value
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K', 'k':
+			multiplier = 1 << 10
+		case 'M', 'm':
+			multiplier = 1 << 20
+		case 'G', 'g':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			number = value[:n-1]
+		}
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s: invalid maximum line length", value)
+	}
+	opts.maxLineLength = n * multiplier
+	return nil
+}
+
+// parseContext parses the line count of -A, -B or -C into dst.
+func parseContext(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s: invalid context length argument", value)
+	}
+	*dst = n
+	return nil
+}
+
+// modeFlag returns a flag selecting an output mode. -q takes precedence
+// over every other mode, -l and -L over -c, as in GNU grep.
+func modeFlag(mode fileSearch.Mode) flagSpec {
+	return flagSpec{apply: func(opts *options, _ string) error {
+		if opts.mode == fileSearch.ModeQuiet {
+			return nil
+		}
+		if mode == fileSearch.ModeCount && opts.mode != fileSearch.ModeLines {
+			return nil
+		}
+		opts.mode = mode
+		return nil
+	}}
+}
+
+// parseArgs parses command line arguments (without the program name) in
+// the style of GNU grep: short flags may be clustered (-rH), long flags
+// accept "--name=value" or "--name value", options and operands may be
+// interleaved, and "--" ends option processing.
+//
+// The first operand is the pattern, every following operand is a file.
+// When patterns are given with -e or -f, every operand is a file.
+//
+// Returns:
+//   - *options: the parsed configuration
+//   - error:    usage error describing the offending argument
+func parseArgs(args []string) (*options, error) {
+	opts := &options{before: -1, after: -1, context: -1, maxCount: -1, color: "never", types: directorywalk.DefaultTypes()}
+	var operands []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			// Everything after "--" is an operand
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+
+		case strings.HasPrefix(arg, "--"):
+			// Long flag: --name or --name=value
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			spec, ok := longFlags[name]
+			if !ok {
+				return nil, fmt.Errorf("unrecognized option '%s'", arg)
+			}
+			if spec.hasArg && !hasValue {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("option '--%s' requires an argument", name)
+				}
+				i++
+				value = args[i]
+			} else if !spec.hasArg && !spec.optionalArg && hasValue {
+				return nil, fmt.Errorf("option '--%s' doesn't allow an argument", name)
+			}
+			if err := spec.apply(opts, value); err != nil {
+				return nil, err
+			}
+
+		case len(arg) > 1 && arg[0] == '-':
+			// Cluster of short flags: -rH, or a flag with a value: -m3, -m 3
+			for j := 1; j < len(arg); j++ {
+				name, ok := shortFlags[arg[j]]
+				if !ok {
+					return nil, fmt.Errorf("invalid option -- '%c'", arg[j])
+				}
+				spec := longFlags[name]
+				var value string
+				if spec.hasArg {
+					if j+1 < len(arg) {
+						value = arg[j+1:]
+					} else if i+1 < len(args) {
+						i++
+						value = args[i]
+					} else {
+						return nil, fmt.Errorf("option requires an argument -- '%c'", arg[j])
+					}
+					j = len(arg)
+				}
+				if err := spec.apply(opts, value); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			// Operand ("-" on its own is standard input)
+			operands = append(operands, arg)
+		}
+	}
+
+	if opts.typeList {
+		// --type-list needs no pattern
+		return opts, nil
+	}
+
+	if !opts.patternGiven {
+		// Without -e or -f the first operand is the pattern
+		if len(operands) == 0 {
+			return nil, fmt.Errorf("no pattern given")
+		}
+		opts.patterns = splitPatterns(operands[0])
+		operands = operands[1:]
+	}
+	opts.files = operands
+
+	if opts.json && opts.mode != fileSearch.ModeLines {
+		return nil, fmt.Errorf("--json cannot be combined with -c, -l, -L or -q")
+	}
+	if opts.longest && opts.syntax == syntaxPerl {
+		return nil, fmt.Errorf("--leftmost-longest cannot be combined with -P")
+	}
+
+	// Types are resolved last, so --type-add may follow the -t using it
+	var err error
+	if opts.typeGlobs, err = opts.types.Globs(opts.typeNames); err != nil {
+		return nil, err
+	}
+	if opts.notTypeGlobs, err = opts.types.Globs(opts.notTypeNames); err != nil {
+		return nil, err
+	}
+
+	// -C only provides the default for -A and -B
+	if opts.before < 0 {
+		opts.before = max(opts.context, 0)
+	}
+	if opts.after < 0 {
+		opts.after = max(opts.context, 0)
+	}
+
+	return opts, nil
+}
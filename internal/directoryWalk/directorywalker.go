@@ -1,35 +1,225 @@
 package directorywalk
 
 import (
-	"fmt"
+	"context"
 	"grep-go/internal/fileSearch"
+	"grep-go/internal/matcher"
+	"grep-go/internal/printer"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
-func DirectorySearch(rootPath string, pattern string) (bool, error) {
-	var filePaths []string
+// Options controls which entries the walk visits.
+//
+// Fields:
+//   - Hidden:   also search files and directories whose name starts
+//     with a '.' (--hidden)
+//   - NoIgnore: do not honor .gitignore, .ignore and the global ignore
+//     file, and search .git directories (--no-ignore)
+//   - Files: --include and --exclude patterns, in command line order
+//   - ExcludeDirs: --exclude-dir patterns; matching directories are
+//     not entered
+//   - TypeGlobs: globs of the -t types; when set, only matching files
+//     are searched
+//   - NotTypeGlobs: globs of the -T types, whose files are skipped
+//   - Follow: follow every symbolic link (-R); otherwise only the
+//     operands are followed and links inside directories are skipped (-r)
+type Options struct {
+	Hidden       bool
+	NoIgnore     bool
+	Files        []FileGlob
+	ExcludeDirs  []string
+	TypeGlobs    []string
+	NotTypeGlobs []string
+	Follow       bool
+}
+
+// DirectorySearch searches every file under the given operands. The
+// walk runs in its own goroutine and streams the paths it finds to the
+// search workers of fileSearch.SearchPaths, so files are searched while
+// the rest of the tree is still being walked. Operands that are not
+// directories are searched as they are.
+//
+// Inside directories, hidden entries and entries excluded by ignore
+// files are skipped, as selected by walkOpts. The --include, --exclude
+// and --exclude-dir patterns of walkOpts apply to the operands too.
+//
+// Entries that cannot be read, such as directories without permission,
+// are reported to opts.Errors and skipped; the rest of the tree is
+// still searched.
+//
+// Returns:
+//   - bool:  true if at least one line was selected
+//   - error: any error encountered while searching; the walk itself
+//     does not fail
+func DirectorySearch(operands []string, walkOpts Options, m matcher.Matcher, opts fileSearch.Options, out printer.Output) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &walker{ctx: ctx, opts: walkOpts, errs: opts.Errors, ancestors: map[fileID]bool{}}
+	if !walkOpts.NoIgnore {
+		if name := globalIgnoreFile(); name != "" {
+			w.global = readIgnoreFile(name, opts.Errors)
+		}
+	}
+
+	paths := make(chan string)
+	walked := make(chan struct{})
+	go func() {
+		defer close(walked)
+		defer close(paths)
+		w.paths = paths
+		w.walk(operands)
+	}()
+
+	found, err := fileSearch.SearchPaths(paths, m, opts, out)
+
+	// The search may stop early (-q): stop the walk too
+	cancel()
+	<-walked
+
+	return found, err
+}
+
+// walker sends the path of every file to search to paths, in walk
+// order, until ctx is cancelled.
+type walker struct {
+	ctx    context.Context
+	opts   Options
+	paths  chan<- string
+	errs   *fileSearch.ErrorLog
+	global []ignoreRule // rules of the global ignore file
 
-	// Collect all file paths
-	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+	// Directories being walked, from the operand down to the current
+	// one, to detect symbolic links leading back to one of them
+	ancestors map[fileID]bool
+}
+
+// walk walks every operand. Entries that cannot be read are reported
+// to errs and skipped.
+//
+// Returns:
+//   - error: ctx.Err() if the walk was cancelled
+func (w *walker) walk(operands []string) error {
+	for _, operand := range operands {
+		info, err := os.Stat(operand)
+		if !w.opts.selectsOperand(operand, info) {
+			continue
+		}
+		if operand == fileSearch.StdinName || err != nil || !info.IsDir() {
+			// Errors opening the operand are reported by the search
+			if err := w.send(operand); err != nil {
+				return err
+			}
+			continue
+		}
+
+		abs, err := filepath.Abs(operand)
 		if err != nil {
-			// fmt.Printf("Error accessing %s: %v\n", path, err)
+			w.errs.Report("error walking the directory: %v\n", err)
+			continue
+		}
+
+		var ignores *ignoreLevel
+		if !w.opts.NoIgnore {
+			ignores = rootIgnores(abs, w.global, w.errs)
+		}
+		if err := w.walkDir(operand, abs, info, ignores); err != nil {
 			return err
 		}
-		if !d.IsDir() {
-			filePaths = append(filePaths, path)
+	}
+
+	return nil
+}
+
+// walkDir walks the directory at path, whose absolute path is abs and
+// whose information is info, in lexical order. ignores holds the rules
+// that apply to its entries, its own ignore files included.
+//
+// Returns:
+//   - error: ctx.Err() if the walk was cancelled
+func (w *walker) walkDir(path, abs string, info os.FileInfo, ignores *ignoreLevel) error {
+	// A directory reached again below itself, through a symbolic link,
+	// would be walked forever
+	if id, ok := idOf(info); ok {
+		if w.ancestors[id] {
+			w.errs.Warn("warning: %s: recursive directory loop\n", path)
+			return nil
 		}
-		return nil
-	})
+		w.ancestors[id] = true
+		defer delete(w.ancestors, id)
+	}
 
+	// On error the entries read so far are still walked
+	entries, err := os.ReadDir(path)
 	if err != nil {
-		return false, fmt.Errorf("error walking the directory: %w", err)
+		w.errs.Report("error walking the directory: %v\n", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !w.opts.Hidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !w.opts.NoIgnore && name == ".git" {
+			continue
+		}
+
+		childPath := filepath.Join(path, name)
+		childAbs := filepath.Join(abs, name)
+		isDir := entry.IsDir()
+		var info os.FileInfo
+		if entry.Type()&fs.ModeSymlink != 0 {
+			// -r only follows the symbolic links given as operands
+			if !w.opts.Follow {
+				continue
+			}
+			if info, err = os.Stat(childPath); err != nil {
+				w.errs.Report("error walking the directory: %v\n", err)
+				continue
+			}
+			isDir = info.IsDir()
+		}
+		if ignores.ignored(childAbs, isDir) {
+			continue
+		}
+
+		if isDir && !w.opts.selectsDir(childPath) || !isDir && !w.opts.selectsFile(childPath) {
+			continue
+		}
+
+		if isDir {
+			if info == nil {
+				if info, err = entry.Info(); err != nil {
+					w.errs.Report("error walking the directory: %v\n", err)
+					continue
+				}
+			}
+			levels := ignores
+			if !w.opts.NoIgnore {
+				levels = ignores.push(childAbs, w.errs)
+			}
+			if err := w.walkDir(childPath, childAbs, info, levels); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.send(childPath); err != nil {
+			return err
+		}
 	}
 
-	// Call FileSearch on all collected files
-	// for i, s := range filePaths {
-	// 	fmt.Println(i, s)
-	// }
+	return nil
+}
 
-	return fileSearch.FileSearch(filePaths, pattern)
+// send hands a path to the search, unless the walk is cancelled.
+func (w *walker) send(path string) error {
+	select {
+	case w.paths <- path:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
 }
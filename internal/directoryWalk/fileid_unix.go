@@ -0,0 +1,27 @@
+//go:build unix
+
+package directorywalk
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file by its device and inode numbers.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// idOf returns the device and inode of a file.
+//
+// Returns:
+//   - fileID: the identity of the file
+//   - bool:   false if the platform gives no such identity
+func idOf(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,108 @@
+package directorywalk
+
+import (
+	"grep-go/internal/fileSearch"
+	"grep-go/internal/glob"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileGlob is an --include or --exclude pattern.
+//
+// Fields:
+//   - Pattern: glob matched against the name of a file (see glob.Match)
+//   - Include: true for --include, false for --exclude
+type FileGlob struct {
+	Pattern string
+	Include bool
+}
+
+// FilterOperands drops the files named on the command line that the
+// --include and --exclude patterns of opts leave out, as GNU grep does
+// without -r. Standard input is always kept. The -t and -T types only
+// apply to the files found by the walk, like in ripgrep.
+func FilterOperands(operands []string, opts Options) []string {
+	var kept []string
+	for _, operand := range operands {
+		if operand == fileSearch.StdinName || opts.selectsGlobs(operand) {
+			kept = append(kept, operand)
+		}
+	}
+	return kept
+}
+
+// selectsFile reports whether a file found by the walk is searched. It
+// must be of one of the -t types if any is given, of none of the -T
+// types, and be selected by the --include and --exclude patterns.
+func (o Options) selectsFile(path string) bool {
+	if len(o.TypeGlobs) > 0 && !matchAny(o.TypeGlobs, path) {
+		return false
+	}
+	if matchAny(o.NotTypeGlobs, path) {
+		return false
+	}
+	return o.selectsGlobs(path)
+}
+
+// selectsGlobs reports whether the --include and --exclude patterns
+// select the file at path. When they contradict each other, the last
+// matching one wins; when none matches, the file is searched unless the
+// first pattern is an --include.
+func (o Options) selectsGlobs(path string) bool {
+	for i := len(o.Files) - 1; i >= 0; i-- {
+		if matchSuffix(o.Files[i].Pattern, path) {
+			return o.Files[i].Include
+		}
+	}
+	return len(o.Files) == 0 || !o.Files[0].Include
+}
+
+// selectsDir reports whether the directory at path is searched, that
+// is whether no --exclude-dir pattern matches it.
+func (o Options) selectsDir(path string) bool {
+	return !matchAny(o.ExcludeDirs, path)
+}
+
+// selectsOperand reports whether an operand of a recursive search is
+// searched: directories must not be excluded by --exclude-dir, other
+// files must be selected by --include and --exclude. Types are not
+// checked: a file named explicitly is searched whatever its type.
+func (o Options) selectsOperand(operand string, info os.FileInfo) bool {
+	if operand == fileSearch.StdinName {
+		return true
+	}
+	if info != nil && info.IsDir() {
+		return o.selectsDir(operand)
+	}
+	return o.selectsGlobs(operand)
+}
+
+// matchSuffix reports whether pattern matches a name suffix of path:
+// the whole path, or a trailing part of it that starts right after a
+// '/'. Patterns without a '/' thus match the base name, and patterns
+// such as "src/*.go" or "**/testdata" the last components of the path.
+func matchSuffix(pattern, path string) bool {
+	path = filepath.ToSlash(path)
+	for {
+		if glob.Match(pattern, path) {
+			return true
+		}
+		i := strings.IndexByte(path, '/')
+		if i < 0 {
+			return false
+		}
+		path = path[i+1:]
+	}
+}
+
+// matchAny reports whether any of the patterns matches a name suffix
+// of path.
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if matchSuffix(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package directorywalk
+
+import (
+	"bufio"
+	"errors"
+	"grep-go/internal/fileSearch"
+	"grep-go/internal/glob"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are the per directory ignore files, lowest precedence
+// first. .git/info/exclude is only read at the root of a repository.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// ignoreRule is a single line of an ignore file, with the gitignore
+// semantics.
+//
+// Fields:
+//   - pattern:  glob matched against the path (anchored) or the name
+//   - negate:   "!pattern", re-includes what an earlier rule ignored
+//   - dirOnly:  "pattern/", only matches directories
+//   - anchored: the pattern contains a '/', so it is matched against
+//     the path relative to the directory of the ignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreRule parses a line of an ignore file.
+//
+// Returns:
+//   - ignoreRule: the rule
+//   - bool:       false for blank lines and comments
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	// "\!" and "\#" are literal: the escape is left to the glob
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = line
+	return rule, true
+}
+
+// matches reports whether the rule applies to an entry, given by its
+// slash separated path relative to the directory of the ignore file.
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return glob.Match(r.pattern, rel)
+}
+
+// ignoreLevel holds the rules of the ignore files of one directory, and
+// points to the level of its parent directory. Rules of deeper levels
+// take precedence; within a level the last matching rule wins, like git.
+type ignoreLevel struct {
+	parent *ignoreLevel
+	base   string // absolute directory the rules are relative to
+	rules  []ignoreRule
+}
+
+// ignored reports whether an entry, given by its absolute path, is
+// excluded by the rules of this level or of any level above it.
+func (l *ignoreLevel) ignored(absPath string, isDir bool) bool {
+	for ; l != nil; l = l.parent {
+		rel, err := filepath.Rel(l.base, absPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		for i := len(l.rules) - 1; i >= 0; i-- {
+			if l.rules[i].matches(rel, isDir) {
+				return !l.rules[i].negate
+			}
+		}
+	}
+	return false
+}
+
+// push returns the level for directory dir below l, reading the ignore
+// files of dir. l itself is returned when dir has none.
+func (l *ignoreLevel) push(dir string, errs *fileSearch.ErrorLog) *ignoreLevel {
+	var rules []ignoreRule
+	if isRepoRoot(dir) {
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, ".git", "info", "exclude"), errs)...)
+	}
+	for _, name := range ignoreFiles {
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, name), errs)...)
+	}
+
+	if len(rules) == 0 {
+		return l
+	}
+	return &ignoreLevel{parent: l, base: dir, rules: rules}
+}
+
+// rootIgnores returns the levels that apply to the walk of directory
+// dir, given as an absolute path: the global ignore file, then the
+// ignore files of the directories between the root of the repository
+// dir belongs to and dir itself. Outside of a repository only the
+// global ignore file and the ignore files of dir apply.
+func rootIgnores(dir string, global []ignoreRule, errs *fileSearch.ErrorLog) *ignoreLevel {
+	// Directories from dir up to the repository root
+	dirs := []string{dir}
+	for d := dir; !isRepoRoot(d); {
+		parent := filepath.Dir(d)
+		if parent == d {
+			// Not in a repository
+			dirs = dirs[:1]
+			break
+		}
+		d = parent
+		dirs = append(dirs, d)
+	}
+
+	// Global rules are relative to the top of the walk
+	top := dirs[len(dirs)-1]
+	var level *ignoreLevel
+	if len(global) > 0 {
+		level = &ignoreLevel{base: top, rules: global}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		level = level.push(dirs[i], errs)
+	}
+	return level
+}
+
+// isRepoRoot reports whether dir is the root of a git repository.
+func isRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// readIgnoreFile reads the rules of an ignore file. A missing file has
+// no rules; other errors are reported to errs.
+func readIgnoreFile(name string, errs *fileSearch.ErrorLog) []ignoreRule {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			errs.Report("error reading ignore file: %v\n", err)
+		}
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		if rule, ok := parseIgnoreRule(line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// globalIgnoreFile returns the path of the global ignore file: the
+// core.excludesFile setting of the git configuration, or git's default
+// $XDG_CONFIG_HOME/git/ignore. Returns "" if it cannot be determined.
+func globalIgnoreFile() string {
+	home, _ := os.UserHomeDir()
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		if home == "" {
+			return ""
+		}
+		config = filepath.Join(home, ".config")
+	}
+
+	// The user's ~/.gitconfig takes precedence over the XDG file
+	candidates := []string{filepath.Join(config, "git", "config")}
+	if home != "" {
+		candidates = append(candidates, filepath.Join(home, ".gitconfig"))
+	}
+	file := filepath.Join(config, "git", "ignore")
+	for _, name := range candidates {
+		if value, ok := excludesFile(name); ok {
+			file = value
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(file, "~/"); ok && home != "" {
+		file = filepath.Join(home, rest)
+	}
+	return file
+}
+
+// excludesFile reads core.excludesFile from a git configuration file.
+// Only the simple "key = value" form of the setting is understood.
+func excludesFile(name string) (string, bool) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	var value string
+	var found bool
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+		case section == "core":
+			key, val, ok := strings.Cut(line, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+				value = strings.Trim(strings.TrimSpace(val), `"`)
+				found = true
+			}
+		}
+	}
+	return value, found
+}
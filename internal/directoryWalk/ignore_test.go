@@ -0,0 +1,94 @@
+package directorywalk
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want ignoreRule
+		ok   bool
+	}{
+		{line: "", ok: false},
+		{line: "# comment", ok: false},
+		{line: "   ", ok: false},
+		{line: "*.log", want: ignoreRule{pattern: "*.log"}, ok: true},
+		{line: "!keep.log", want: ignoreRule{pattern: "keep.log", negate: true}, ok: true},
+		{line: "build/", want: ignoreRule{pattern: "build", dirOnly: true}, ok: true},
+		{line: "/rootonly.txt", want: ignoreRule{pattern: "rootonly.txt", anchored: true}, ok: true},
+		{line: "a/**/b", want: ignoreRule{pattern: "a/**/b", anchored: true}, ok: true},
+		{line: "trailing  ", want: ignoreRule{pattern: "trailing"}, ok: true},
+		{line: `space\ `, want: ignoreRule{pattern: `space\ `}, ok: true},
+		{line: `\!important`, want: ignoreRule{pattern: `\!important`}, ok: true},
+		{line: `\#hash`, want: ignoreRule{pattern: `\#hash`}, ok: true},
+		{line: "crlf\r", want: ignoreRule{pattern: "crlf"}, ok: true},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseIgnoreRule(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseIgnoreRule(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	rules := func(lines ...string) []ignoreRule {
+		var parsed []ignoreRule
+		for _, line := range lines {
+			if rule, ok := parseIgnoreRule(line); ok {
+				parsed = append(parsed, rule)
+			}
+		}
+		return parsed
+	}
+
+	top := &ignoreLevel{
+		base:  root,
+		rules: rules("*.log", "!keep.log", "build/", "/rootonly.txt", "a/**/b", `space\ `, `\!important`),
+	}
+	// A nested ignore file overrides its parents
+	nested := &ignoreLevel{
+		parent: top,
+		base:   filepath.Join(root, "src"),
+		rules:  rules("!debug.log", "*.tmp"),
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"logs/app.log", false, true},
+		{"keep.log", false, false},
+		{"logs/keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"rootonly.txt", false, true},
+		{"src/rootonly.txt", false, false},
+		{"a/b", false, true},
+		{"a/x/y/b", true, true},
+		{"x/a/b", false, false},
+		{"space ", false, true},
+		{"space", false, false},
+		{"!important", false, true},
+		{"important", false, false},
+		{"src/debug.log", false, false},
+		{"src/other.log", false, true},
+		{"src/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		abs := filepath.Join(root, filepath.FromSlash(tt.path))
+		if got := nested.ignored(abs, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
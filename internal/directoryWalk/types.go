@@ -0,0 +1,85 @@
+package directorywalk
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Types is a registry of named file types (-t, -T), each mapping to the
+// globs matching the names of its files.
+type Types map[string][]string
+
+// DefaultTypes returns the built-in file types.
+func DefaultTypes() Types {
+	return Types{
+		"c":        {"*.c", "*.h"},
+		"cpp":      {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.hxx", "*.h"},
+		"css":      {"*.css", "*.scss", "*.sass", "*.less"},
+		"go":       {"*.go"},
+		"html":     {"*.htm", "*.html"},
+		"java":     {"*.java"},
+		"js":       {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+		"json":     {"*.json"},
+		"make":     {"Makefile", "makefile", "GNUmakefile", "*.mk", "*.mak"},
+		"markdown": {"*.md", "*.markdown"},
+		"md":       {"*.md", "*.markdown"},
+		"py":       {"*.py", "*.pyi"},
+		"rb":       {"*.rb", "Gemfile", "Rakefile"},
+		"rust":     {"*.rs"},
+		"sh":       {"*.sh", "*.bash", "*.zsh"},
+		"sql":      {"*.sql"},
+		"toml":     {"*.toml"},
+		"ts":       {"*.ts", "*.tsx", "*.mts", "*.cts"},
+		"txt":      {"*.txt"},
+		"xml":      {"*.xml"},
+		"yaml":     {"*.yaml", "*.yml"},
+	}
+}
+
+// Add extends the registry with a definition of the form "name:glob"
+// (--type-add). The glob is added to the type, which is created if it
+// does not exist yet.
+func (t Types) Add(def string) error {
+	name, pattern, ok := strings.Cut(def, ":")
+	if !ok || name == "" || pattern == "" {
+		return fmt.Errorf("invalid file type definition '%s', expected 'name:glob'", def)
+	}
+	t[name] = append(t[name], pattern)
+	return nil
+}
+
+// Globs returns the globs of the given types.
+//
+// Returns:
+//   - []string: globs of every type, in order
+//   - error:    a type is not in the registry
+func (t Types) Globs(names []string) ([]string, error) {
+	var globs []string
+	for _, name := range names {
+		patterns, ok := t[name]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized file type '%s'", name)
+		}
+		globs = append(globs, patterns...)
+	}
+	return globs, nil
+}
+
+// List writes the registry to w, one "name: glob, glob" line per type,
+// sorted by name (--type-list).
+func (t Types) List(w io.Writer) error {
+	names := make([]string, 0, len(t))
+	for name := range t {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", name, strings.Join(t[name], ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
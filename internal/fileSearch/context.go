@@ -0,0 +1,47 @@
+package fileSearch
+
+import (
+	"grep-go/internal/printer"
+)
+
+// contextBuffer is a fixed size ring buffer holding the most recent
+// non-selected lines, so they can be printed as leading context (-B)
+// once a selected line shows up.
+type contextBuffer struct {
+	lines []printer.Line
+	start int // index of the oldest buffered line
+	size  int // number of buffered lines
+}
+
+// newContextBuffer creates a buffer keeping at most capacity lines.
+func newContextBuffer(capacity int) *contextBuffer {
+	return &contextBuffer{lines: make([]printer.Line, capacity)}
+}
+
+// push adds a line, dropping the oldest one when the buffer is full.
+func (b *contextBuffer) push(line printer.Line) {
+	capacity := len(b.lines)
+	if capacity == 0 {
+		return
+	}
+
+	if b.size < capacity {
+		b.lines[(b.start+b.size)%capacity] = line
+		b.size++
+		return
+	}
+
+	b.lines[b.start] = line
+	b.start = (b.start + 1) % capacity
+}
+
+// drain hands every buffered line to fn, oldest first, and empties
+// the buffer.
+func (b *contextBuffer) drain(fn func(printer.Line)) {
+	capacity := len(b.lines)
+	for i := 0; i < b.size; i++ {
+		fn(b.lines[(b.start+i)%capacity])
+	}
+	b.start = 0
+	b.size = 0
+}
@@ -0,0 +1,62 @@
+package fileSearch
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrorLog collects the errors met while searching, such as files that
+// cannot be opened or directories that cannot be read. They are not
+// fatal: the entry is reported on stderr and skipped, and the search
+// goes on. It is safe for concurrent use; a nil ErrorLog only prints.
+type ErrorLog struct {
+	suppress bool // -s: count errors without printing them
+
+	mu    sync.Mutex
+	count int
+}
+
+// NewErrorLog creates an ErrorLog. With suppress, errors are counted but
+// not printed (-s).
+func NewErrorLog(suppress bool) *ErrorLog {
+	return &ErrorLog{suppress: suppress}
+}
+
+// Report records an error and prints it on stderr, formatted with
+// fmt.Fprintf, unless messages are suppressed.
+func (l *ErrorLog) Report(format string, args ...any) {
+	if l == nil {
+		fmt.Fprintf(os.Stderr, format, args...)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.count++
+	if !l.suppress {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// Warn prints a warning on stderr, formatted with fmt.Fprintf, unless
+// messages are suppressed. Unlike Report, it does not make the search
+// fail.
+func (l *ErrorLog) Warn(format string, args ...any) {
+	if l != nil && l.suppress {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// Failed reports whether any error was recorded.
+func (l *ErrorLog) Failed() bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count > 0
+}
@@ -2,89 +2,378 @@ package fileSearch
 
 import (
 	"bufio"
-	"container/list"
-	"fmt"
+	"bytes"
 	"grep-go/internal/matcher"
+	"grep-go/internal/printer"
+	"io"
 	"os"
 )
 
+// StdinName is the file operand that selects standard input, and
+// StdinLabel is the name used for it in prefixed output.
+const (
+	StdinName  = "-"
+	StdinLabel = "(standard input)"
+)
+
+// Mode selects what is reported for every searched file.
+type Mode int
+
+const (
+	ModeLines             Mode = iota // print every selected line
+	ModeCount                         // -c: print the number of selected lines
+	ModeFilesWithMatch                // -l: print the names of files with a selected line
+	ModeFilesWithoutMatch             // -L: print the names of files without a selected line
+	ModeQuiet                         // -q: print nothing, stop at the first selected line
+)
+
+// BinaryMode selects how files holding binary data are searched. A file
+// is binary when its first block contains a NUL byte.
+type BinaryMode int
+
+const (
+	BinaryMatch BinaryMode = iota // report "Binary file X matches" instead of its lines
+	BinaryText                    // -a: search binary files as text
+	BinarySkip                    // -I: binary files never match
+)
+
+// LongLinePolicy selects what happens to lines longer than the maximum
+// line length. They are never searched.
+type LongLinePolicy int
+
+const (
+	LongLinesWarn LongLinePolicy = iota // skip them, with a warning per file
+	LongLinesSkip                       // skip them silently
+)
+
+// binaryBlockSize is the size of the first block read from a file, in
+// which binary data is looked for.
+const binaryBlockSize = 32 * 1024
+
+// Options controls how files are searched.
+type Options struct {
+	// Mode selects what is reported for every file.
+	Mode Mode
+
+	// Spans asks for the span of every match on a printed line to be
+	// reported (needed by -o and --color), not just whether it matched.
+	Spans bool
+
+	// Invert selects the lines that do NOT match the pattern (-v).
+	// Counts and the exit status are based on the selected lines.
+	Invert bool
+
+	// Before and After are the number of context lines printed before
+	// and after every selected line (-B, -A, -C). Only used in ModeLines.
+	Before int
+	After  int
+
+	// MaxCount stops reading a file after that many selected lines (-m).
+	// A negative value means no limit.
+	MaxCount int
+
+	// NullData splits the input into NUL terminated records instead of
+	// lines (-z).
+	NullData bool
+
+	// Workers is the number of files searched concurrently (-j). Values
+	// below 2 search the files one after the other.
+	Workers int
+
+	// Unordered prints the results of every file as soon as it has been
+	// searched, instead of in the order the files were given or walked
+	// (--no-sort).
+	Unordered bool
+
+	// Binary selects how binary files are searched (--binary-files).
+	// Binary detection is off with NullData, where NUL bytes end records.
+	Binary BinaryMode
+
+	// MaxLineLength is the length in bytes of the longest line searched,
+	// 0 for no limit (--max-line-length). Longer lines are handled as
+	// LongLines says instead of failing the search of the file.
+	MaxLineLength int
+	LongLines     LongLinePolicy
+
+	// Errors receives the files that could not be searched. They are
+	// skipped, and the search goes on with the next one.
+	Errors *ErrorLog
+}
+
+// firstOnly reports whether a file can stop being read at its first
+// selected line, because only the presence of a match matters.
+func (o Options) firstOnly() bool {
+	return o.Mode == ModeFilesWithMatch || o.Mode == ModeFilesWithoutMatch || o.Mode == ModeQuiet
+}
+
 // FileSearch iterates over multiple files and searches for a given pattern.
-// It prints all matching lines in the format "<file>:<line>".
+// Results are handed to the printer as they are found: selected lines,
+// per-file counts or file names, depending on opts.Mode. The files are
+// searched by opts.Workers workers, see SearchPaths.
 //
 // Params:
-//   - filePaths: list of file paths to search
-//   - pattern:   search pattern
+//   - filePaths: list of file paths to search ("-" means standard input)
+//   - m:         matcher for the search patterns
+//   - opts:      search options
+//   - out:       printer receiving the results
 //
 // Returns:
-//   - bool:  true if at least one match was found
+//   - bool:  true if at least one line was selected (for -L: if at
+//     least one file was listed)
 //   - error: any error encountered while searching
-func FileSearch(filePaths []string, pattern string) (bool, error) {
-	foundOne := false
-
+func FileSearch(filePaths []string, m matcher.Matcher, opts Options, out printer.Output) (bool, error) {
+	paths := make(chan string, len(filePaths))
 	for _, filePath := range filePaths {
-		file, err := os.Open(filePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "File I/O error: %v\n", err)
-			// keep going instead of stopping on a single bad file
-			continue
-		}
+		paths <- filePath
+	}
+	close(paths)
 
-		// Close each file after processing
-		func() {
-			defer file.Close()
+	return SearchPaths(paths, m, opts, out)
+}
 
-			found, matches, singleFileErr := SingleFileSearch(file, pattern)
-			if singleFileErr != nil {
-				fmt.Fprintf(os.Stderr, "Single file search error for %s: %v\n", filePath, singleFileErr)
-				return
-			}
+// searchFile searches a single file operand and reports its results to
+// out. Errors are reported to opts.Errors and the file is skipped.
+//
+// Returns:
+//   - bool: true if a line was selected (for -L: if the file was listed)
+func searchFile(filePath string, m matcher.Matcher, opts Options, out printer.Output) bool {
+	file, displayName, err := openFile(filePath)
+	if err != nil {
+		opts.Errors.Report("File I/O error: %v\n", err)
+		// keep going instead of stopping on a single bad file
+		return false
+	}
 
-			if found {
-				for lin := matches.Front(); lin != nil; lin = lin.Next() {
-					lineText := lin.Value.(string)
-					fmt.Printf("%s:%s\n", filePath, lineText)
-				}
-				foundOne = true
-			}
-		}()
+	// Close each file after processing
+	if file != os.Stdin {
+		defer file.Close()
+	}
+
+	out.BeginFile(displayName)
+	stats, singleFileErr := SingleFileSearch(file, displayName, m, opts, out)
+	out.EndFile(displayName, stats)
+	if singleFileErr != nil {
+		opts.Errors.Report("Single file search error for %s: %v\n", displayName, singleFileErr)
+		return false
 	}
 
-	return foundOne, nil
+	count := stats.SelectedLines
+
+	switch opts.Mode {
+	case ModeCount:
+		out.PrintCount(displayName, count)
+	case ModeFilesWithMatch:
+		if count > 0 {
+			out.PrintFileName(displayName)
+		}
+	case ModeFilesWithoutMatch:
+		if count == 0 {
+			out.PrintFileName(displayName)
+			return true
+		}
+		return false
+	}
+
+	return count > 0
 }
 
-// SingleFileSearch scans a single file line-by-line and checks each line
-// against the given pattern.
+// openFile opens a file operand for reading, mapping "-" to standard input.
 //
 // Returns:
-//   - bool:      true if at least one match found
-//   - *list.List: linked list of matched lines
-//   - error:     error if parsing/matching fails
-func SingleFileSearch(file *os.File, pattern string) (bool, *list.List, error) {
-	scanner := bufio.NewScanner(file)
-	matches := list.New()
+//   - *os.File: the opened file
+//   - string:   the name to display for this file in output
+//   - error:    error if the file could not be opened
+func openFile(filePath string) (*os.File, string, error) {
+	if filePath == StdinName {
+		return os.Stdin, StdinLabel, nil
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		found, err := matcher.MatchPattern([]byte(line), pattern)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	return file, filePath, nil
+}
+
+// SingleFileSearch scans a single file line-by-line and selects the lines
+// that match the given pattern (or that don't, when opts.Invert is set).
+// In ModeLines every selected line is printed as soon as it is found,
+// with its line number, byte offset and, if requested, its match spans,
+// surrounded by opts.Before and opts.After lines of context. Leading
+// context is kept in a ring buffer, so overlapping context windows of
+// nearby matches are merged and no line is printed twice.
+// Modes that only need to know whether the file matched stop reading at
+// the first selected line.
+//
+// A binary file (see BinaryMode) is searched as text with BinaryText,
+// and not read at all with BinarySkip. Otherwise its lines are never
+// printed: in ModeLines the first selected line stops the search and
+// the file is reported with PrintBinaryMatch.
+//
+// Lines of any length are read. Lines longer than opts.MaxLineLength,
+// when set, are skipped as if absent, keeping line numbers and byte
+// offsets exact, and reported according to opts.LongLines.
+//
+// With opts.MaxCount the file stops being read after that many selected
+// lines, once their trailing context has been printed. The file is then
+// repositioned right after the last selected line when it is seekable,
+// so a caller sharing standard input can carry on from there.
+//
+// Params:
+//   - file:        the file to read
+//   - displayName: name of the file in output
+//   - m:           matcher for the search patterns
+//   - opts:        search options
+//   - out:         printer receiving the selected lines
+//
+// Returns:
+//   - printer.Stats: number of selected lines (at most 1 when only the
+//     first selected line matters), matches and bytes read
+//   - error:         error if reading the file fails
+func SingleFileSearch(file *os.File, displayName string, m matcher.Matcher, opts Options, out printer.Output) (printer.Stats, error) {
+	// Standard input may be shared with an earlier command that left it
+	// past its start; -m repositions it relative to this point. Pipes
+	// and terminals cannot seek, and are not repositioned
+	start, seekErr := file.Seek(0, io.SeekCurrent)
 
+	reader := bufio.NewReaderSize(file, binaryBlockSize)
+
+	var stats printer.Stats
+	nulOffset, err := binaryOffset(reader, opts)
+	if err != nil {
+		return stats, err
+	}
+	binary := nulOffset >= 0
+	if binary && opts.Binary == BinarySkip {
+		return stats, nil
+	}
+	binaryLines := binary && opts.Mode == ModeLines
+
+	// Records are lines, or NUL terminated chunks with --null-data. The
+	// reader strips the terminator, so remember how many bytes each
+	// record really consumed to keep byte offsets exact
+	terminator := byte('\n')
+	if opts.NullData {
+		terminator = 0
+	}
+	records := newRecordReader(reader, terminator, opts.MaxLineLength)
+
+	lineNumber := 0
+	var offset int64
+	skipped := 0 // lines longer than opts.MaxLineLength
+
+	before := newContextBuffer(opts.Before)
+	afterLeft := 0 // trailing context lines still to print
+
+	var stopOffset int64 // offset just past the last selected line
+
+	for {
+		record, consumed, tooLong, err := records.next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error in file search: %v\n", err)
-			return false, nil, err
+			// I/O error, reported by the caller
+			return stats, err
+		}
+		lineNumber++
+		lineOffset := offset
+		offset += int64(consumed)
+
+		if tooLong {
+			// Neither selected nor printed as context
+			skipped++
+			continue
+		}
+		line := string(record)
+
+		selected := printer.Line{
+			Number:     lineNumber,
+			Offset:     lineOffset,
+			Text:       line,
+			Terminator: string(records.end),
+		}
+
+		if opts.MaxCount >= 0 && stats.SelectedLines >= opts.MaxCount {
+			// Enough lines selected: only trailing context is left
+			if afterLeft == 0 {
+				break
+			}
+			out.PrintContext(displayName, selected)
+			afterLeft--
+			continue
+		}
+
+		var found bool
+		if opts.Spans && opts.Mode == ModeLines {
+			selected.Matches = m.FindAll([]byte(line))
+			found = len(selected.Matches) > 0
+		} else {
+			found = m.Match([]byte(line))
+		}
+
+		// With -v the non-matching lines are the selected ones
+		if found == opts.Invert {
+			if opts.Mode == ModeLines {
+				if afterLeft > 0 {
+					out.PrintContext(displayName, selected)
+					afterLeft--
+				} else {
+					before.push(selected)
+				}
+			}
+			continue
 		}
 
-		if found {
-			matches.PushBack(line)
+		stats.SelectedLines++
+		stats.Matches += selected.MatchCount()
+		stopOffset = offset
+		if opts.firstOnly() {
+			break
 		}
+		if binaryLines {
+			out.PrintBinaryMatch(displayName, nulOffset)
+			break
+		}
+		if opts.Mode == ModeLines {
+			before.drain(func(context printer.Line) {
+				out.PrintContext(displayName, context)
+			})
+			out.PrintLine(displayName, selected)
+			afterLeft = opts.After
+		}
+	}
+
+	if skipped > 0 && opts.LongLines == LongLinesWarn {
+		opts.Errors.Warn("warning: %s: skipped %d line(s) longer than %d bytes\n", displayName, skipped, opts.MaxLineLength)
 	}
 
-	// Handle scanner error (I/O or bufio issue)
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		return false, nil, err
+	stats.BytesSearched = offset
+
+	if opts.MaxCount >= 0 && stats.SelectedLines >= opts.MaxCount && seekErr == nil {
+		// Leave the file right after the last selected line
+		file.Seek(start+stopOffset, io.SeekStart)
+	}
+
+	return stats, nil
+}
+
+// binaryOffset looks for binary data in the file read by reader: a NUL
+// byte in the first block it reads. Nothing is consumed. With
+// BinaryText or NullData no data is considered binary.
+//
+// Returns:
+//   - int64: offset of the first NUL byte, -1 if the file is text
+//   - error: error reading the first block
+func binaryOffset(reader *bufio.Reader, opts Options) (int64, error) {
+	if opts.Binary == BinaryText || opts.NullData {
+		return -1, nil
 	}
 
-	if matches.Len() > 0 {
-		return true, matches, nil
+	// A single read, so a slow pipe is not waited for until the block is full
+	if _, err := reader.Peek(1); err != nil && err != io.EOF {
+		return -1, err
 	}
-	return false, nil, nil
+	block, _ := reader.Peek(reader.Buffered())
+	return int64(bytes.IndexByte(block, 0)), nil
 }
@@ -0,0 +1,133 @@
+package fileSearch
+
+import (
+	"grep-go/internal/matcher"
+	"grep-go/internal/printer"
+	"sync"
+)
+
+// windowPerWorker is the number of files per worker that may be searched
+// ahead of the file being printed. It bounds the memory used by buffered
+// results whatever the number of files.
+const windowPerWorker = 4
+
+// fileResult is the search of one file, shared between the worker that
+// searches it and the goroutine that prints it.
+type fileResult struct {
+	path   string
+	output *printer.Buffer
+	found  bool          // set by the worker before done is closed
+	done   chan struct{} // closed once the file has been searched
+}
+
+// SearchPaths searches every file received on paths until the channel
+// is closed. Paths can be sent while the search is running, so a
+// directory walk and the search of the files it finds overlap.
+//
+// With more than one worker, the files are searched concurrently by
+// opts.Workers goroutines, each recording the output of a file into a
+// printer.Buffer. Results are printed in the order the paths arrived:
+// the file whose turn it is streams its output directly, the files
+// searched ahead of it are buffered, at most windowPerWorker per worker.
+// With opts.Unordered (and with -q, which prints nothing) every file is
+// printed as soon as it has been searched instead. With a single worker
+// every file is searched and printed directly, in order.
+//
+// With -q the search stops at the first selected line; paths that are
+// still being sent are then left unread, the sender must stop on its own.
+//
+// Returns:
+//   - bool:  true if at least one line was selected (for -L: if at
+//     least one file was listed)
+//   - error: any error encountered while searching
+func SearchPaths(paths <-chan string, m matcher.Matcher, opts Options, out printer.Output) (bool, error) {
+	if opts.Workers < 2 {
+		foundOne := false
+		for path := range paths {
+			foundOne = searchFile(path, m, opts, out) || foundOne
+
+			// With -q the answer is known as soon as anything matched
+			if foundOne && opts.Mode == ModeQuiet {
+				break
+			}
+		}
+		return foundOne, nil
+	}
+
+	unordered := opts.Unordered || opts.Mode == ModeQuiet
+	window := windowPerWorker * opts.Workers
+
+	jobs := make(chan *fileResult)
+	queue := make(chan *fileResult, window) // files in arrival order, or as they finish when unordered
+	stop := make(chan struct{})
+
+	// Hand the paths out to the workers; when ordered, queue them for
+	// printing in arrival order too. The queue being full holds the
+	// walk back until the printing catches up.
+	go func() {
+		defer close(jobs)
+		if !unordered {
+			defer close(queue)
+		}
+
+		for path := range paths {
+			r := &fileResult{path: path, output: printer.NewBuffer(), done: make(chan struct{})}
+			if !unordered {
+				select {
+				case queue <- r:
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case jobs <- r:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				r.found = searchFile(r.path, m, opts, r.output)
+				close(r.done)
+
+				if unordered {
+					select {
+					case queue <- r:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	if unordered {
+		go func() {
+			wg.Wait()
+			close(queue)
+		}()
+	}
+
+	foundOne := false
+	for r := range queue {
+		// Print what the file has produced so far and let the worker
+		// write the rest straight through
+		r.output.Stream(out)
+		<-r.done
+		foundOne = foundOne || r.found
+
+		// With -q the answer is known as soon as anything matched
+		if foundOne && opts.Mode == ModeQuiet {
+			close(stop)
+			break
+		}
+	}
+
+	return foundOne, nil
+}
@@ -0,0 +1,93 @@
+package fileSearch
+
+import (
+	"bufio"
+	"io"
+)
+
+// recordReader cuts input into records ended by terminator: '\n' for
+// lines, or NUL for --null-data. The terminator is not part of the
+// record, and for newline terminated lines a trailing '\r' is dropped
+// as well, like bufio.ScanLines does. A final record without
+// terminator is still returned.
+//
+// Unlike bufio.Scanner, records of any length are read: the buffer
+// holding the current record grows as needed. With maxLen > 0, records
+// longer than maxLen bytes are consumed without being kept, so a single
+// huge line cannot exhaust memory.
+type recordReader struct {
+	r          *bufio.Reader
+	terminator byte
+	maxLen     int    // longest record kept, 0 for no limit
+	buf        []byte // the current record, reused between records
+
+	// terminator of the record last returned, as read: "\n", "\r\n",
+	// NUL, or empty for a final record without terminator
+	end []byte
+}
+
+// newRecordReader creates a recordReader reading from r.
+func newRecordReader(r *bufio.Reader, terminator byte, maxLen int) *recordReader {
+	return &recordReader{r: r, terminator: terminator, maxLen: maxLen}
+}
+
+// next reads the next record. The returned slice is only valid until
+// the next call, and so is rr.end, which holds its terminator.
+//
+// Returns:
+//   - []byte: the record, without terminator; nil if it is too long
+//   - int:    number of input bytes taken by the record, terminator
+//     included, so callers can keep exact byte offsets
+//   - bool:   whether the record was longer than maxLen and skipped
+//   - error:  io.EOF once every record has been read, or a read error
+func (rr *recordReader) next() ([]byte, int, bool, error) {
+	rr.buf = rr.buf[:0]
+	consumed := 0
+	tooLong := false
+
+	for {
+		chunk, err := rr.r.ReadSlice(rr.terminator)
+		consumed += len(chunk)
+
+		if !tooLong {
+			rr.buf = append(rr.buf, chunk...)
+			length := len(rr.buf)
+			if err == nil {
+				length-- // the terminator does not count
+			}
+			if rr.maxLen > 0 && length > rr.maxLen {
+				tooLong = true
+				rr.buf = rr.buf[:0]
+			}
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			// The record goes on past the buffer of the reader
+			continue
+		case err == io.EOF && consumed > 0:
+			// Last record, without terminator
+		case err != nil:
+			return nil, consumed, false, err
+		}
+
+		if tooLong {
+			return nil, consumed, true, nil
+		}
+		record := rr.buf
+		if err == nil {
+			record = record[:len(record)-1]
+		}
+		record = dropCR(record, rr.terminator)
+		rr.end = rr.buf[len(record):]
+		return record, consumed, false, nil
+	}
+}
+
+// dropCR removes the '\r' of a "\r\n" line ending.
+func dropCR(record []byte, terminator byte) []byte {
+	if terminator == '\n' && len(record) > 0 && record[len(record)-1] == '\r' {
+		return record[:len(record)-1]
+	}
+	return record
+}
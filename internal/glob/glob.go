@@ -0,0 +1,162 @@
+package glob
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Match reports whether a slash separated path matches a shell pattern,
+// as used by .gitignore files and the --include / --exclude options.
+//
+// Supported syntax:
+//   - '*' matches any run of characters except '/'
+//   - '?' matches a single character except '/'
+//   - [abc], [a-z], [!abc] or [^abc] match a single character except '/'
+//   - '**' as a whole path segment matches any number of directories:
+//     "**/a" matches a at any depth, "a/**" everything inside a, and
+//     "a/**/b" b anywhere below a; anywhere else it acts as '*'
+//   - '\' makes the next character literal
+//
+// A '[' without a closing ']' is a literal character.
+func Match(pattern, name string) bool {
+	return match(pattern, name, true)
+}
+
+// match matches pattern against name. segmentStart reports whether the
+// pattern starts a path segment, where '**' is special.
+func match(pattern, name string, segmentStart bool) bool {
+	for pattern != "" {
+		if segmentStart && strings.HasPrefix(pattern, "**") && (len(pattern) == 2 || pattern[2] == '/') {
+			return matchDirectories(pattern[2:], name)
+		}
+		segmentStart = false
+
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			// Try every split of the current segment, shortest first
+			for i := 0; ; {
+				if match(pattern, name[i:], false) {
+					return true
+				}
+				if i == len(name) || name[i] == '/' {
+					return false
+				}
+				_, size := utf8.DecodeRuneInString(name[i:])
+				i += size
+			}
+
+		case '?':
+			if name == "" || name[0] == '/' {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(name)
+			name = name[size:]
+			pattern = pattern[1:]
+
+		case '[':
+			if name == "" || name[0] == '/' {
+				return false
+			}
+			r, size := utf8.DecodeRuneInString(name)
+			matched, width, ok := matchClass(pattern, r)
+			if !ok {
+				// Unterminated class: a literal '['
+				if name[0] != '[' {
+					return false
+				}
+				name = name[1:]
+				pattern = pattern[1:]
+				continue
+			}
+			if !matched {
+				return false
+			}
+			name = name[size:]
+			pattern = pattern[width:]
+
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if name == "" || name[0] != pattern[0] {
+				return false
+			}
+			segmentStart = pattern[0] == '/'
+			name = name[1:]
+			pattern = pattern[1:]
+		}
+	}
+
+	return name == ""
+}
+
+// matchDirectories matches what follows a '**' segment: rest is empty
+// (everything matches) or starts with '/', and the segment stands for
+// any number of leading directories of name, including none.
+func matchDirectories(rest, name string) bool {
+	if rest == "" {
+		return true
+	}
+	rest = rest[1:]
+
+	for {
+		if match(rest, name, true) {
+			return true
+		}
+		i := strings.IndexByte(name, '/')
+		if i < 0 {
+			return false
+		}
+		name = name[i+1:]
+	}
+}
+
+// matchClass matches r against the bracket expression at the start of
+// pattern.
+//
+// Returns:
+//   - bool: whether r belongs to the class
+//   - int:  length of the bracket expression in pattern
+//   - bool: false if the class has no closing ']'
+func matchClass(pattern string, r rune) (bool, int, bool) {
+	i := 1
+	negated := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negated = true
+		i++
+	}
+
+	matched := false
+	for first := true; ; first = false {
+		if i >= len(pattern) {
+			return false, 0, false
+		}
+		if pattern[i] == ']' && !first {
+			return matched != negated, i + 1, true
+		}
+
+		lo, size := classChar(pattern[i:])
+		i += size
+		hi := lo
+
+		// Range a-z, unless '-' is the last character of the class
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, size = classChar(pattern[i+1:])
+			i += 1 + size
+		}
+
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+}
+
+// classChar decodes a possibly escaped character of a bracket expression.
+func classChar(s string) (rune, int) {
+	if s[0] == '\\' && len(s) > 1 {
+		r, size := utf8.DecodeRuneInString(s[1:])
+		return r, 1 + size
+	}
+	return utf8.DecodeRuneInString(s)
+}
@@ -0,0 +1,42 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.log", "debug.log", true},
+		{"*.log", "logs/debug.log", false},
+		{"*.log", "debug.txt", false},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+		{"[a-c]x", "bx", true},
+		{"[!a-c]x", "bx", false},
+		{"[^a-c]x", "dx", true},
+		{"[abc", "[abc", true},
+		{`\*x`, "*x", true},
+		{`\*x`, "ax", false},
+		{`\!keep`, "!keep", true},
+		{`foo\ `, "foo ", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/tool/main.go", true},
+		{"build/**", "build/out/a.o", true},
+		{"build/**", "build", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/yb", false},
+		{"a**b", "axxb", true},
+		{"a**b", "ax/xb", false},
+		{"*", "", true},
+		{"é?", "éa", true},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
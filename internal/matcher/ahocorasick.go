@@ -0,0 +1,181 @@
+package matcher
+
+import (
+	"slices"
+)
+
+// ahoCorasick searches for many fixed strings at once. The needles are
+// stored in a trie whose states are linked to the state of their longest
+// proper suffix (the failure link), so the text is scanned a single time
+// whatever the number of needles.
+//
+// Matches follow GNU grep -F: the leftmost match wins, and among the
+// needles matching at that position the longest one.
+type ahoCorasick struct {
+	states   []acState
+	hasEmpty bool // an empty needle matches everywhere
+	opts     Options
+}
+
+// acState is a node of the trie.
+type acState struct {
+	next  map[byte]int32 // trie transitions
+	fail  int32          // state of the longest proper suffix in the trie
+	depth int            // length of the text leading to this state
+	ends  []int          // lengths of the needles ending here, including through failure links
+}
+
+// newAhoCorasick builds the automaton for the given needles.
+func newAhoCorasick(needles []string, opts Options) *ahoCorasick {
+	ac := &ahoCorasick{states: []acState{{next: map[byte]int32{}}}, opts: opts}
+
+	// Build the trie
+	for _, needle := range needles {
+		if needle == "" {
+			ac.hasEmpty = true
+			continue
+		}
+
+		state := int32(0)
+		for i := 0; i < len(needle); i++ {
+			next, ok := ac.states[state].next[needle[i]]
+			if !ok {
+				next = int32(len(ac.states))
+				ac.states = append(ac.states, acState{
+					next:  map[byte]int32{},
+					depth: ac.states[state].depth + 1,
+				})
+				ac.states[state].next[needle[i]] = next
+			}
+			state = next
+		}
+		ac.states[state].ends = append(ac.states[state].ends, len(needle))
+	}
+
+	// Compute failure links breadth first, so the failure state of every
+	// state is complete before the state itself is visited
+	queue := []int32{}
+	for _, child := range ac.states[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for b, child := range ac.states[state].next {
+			fail := ac.states[state].fail
+			for {
+				if next, ok := ac.states[fail].next[b]; ok {
+					ac.states[child].fail = next
+					break
+				}
+				if fail == 0 {
+					ac.states[child].fail = 0
+					break
+				}
+				fail = ac.states[fail].fail
+			}
+
+			// Needles ending at the failure state also end here
+			failEnds := ac.states[ac.states[child].fail].ends
+			ac.states[child].ends = append(ac.states[child].ends, failEnds...)
+
+			queue = append(queue, child)
+		}
+	}
+
+	return ac
+}
+
+func (ac *ahoCorasick) Match(line []byte) bool {
+	_, _, found := ac.FindAt(line, 0)
+	return found
+}
+
+func (ac *ahoCorasick) FindAt(line []byte, start int) (int, int, bool) {
+	return findFixed(ac, ac.opts, line, start)
+}
+
+// find scans the line from start and returns the leftmost-longest
+// match. Scanning stops as soon as no needle still in progress can
+// start at or before the best match found so far.
+func (ac *ahoCorasick) find(line []byte, start int) (int, int, bool) {
+	if start > len(line) {
+		return -1, -1, false
+	}
+	if ac.hasEmpty {
+		// The empty needle matches right here, so the leftmost match
+		// starts here too
+		return start, ac.endsAt(line, start)[0], true
+	}
+
+	bestStart, bestEnd := -1, -1
+	state := int32(0)
+
+	for i := start; i < len(line); i++ {
+		state = ac.step(state, line[i])
+
+		for _, length := range ac.states[state].ends {
+			matchStart := i + 1 - length
+			if bestStart < 0 || matchStart < bestStart || (matchStart == bestStart && i+1 > bestEnd) {
+				bestStart, bestEnd = matchStart, i+1
+			}
+		}
+
+		// Every needle still in progress started at i+1-depth or later
+		if bestStart >= 0 && i+1-ac.states[state].depth > bestStart {
+			break
+		}
+	}
+
+	if bestStart < 0 {
+		return -1, -1, false
+	}
+	return bestStart, bestEnd, true
+}
+
+func (ac *ahoCorasick) FindAll(line []byte) [][2]int {
+	return findAll(line, ac.FindAt)
+}
+
+// step follows the transition for byte b from state, falling back along
+// failure links when the trie has no such transition.
+func (ac *ahoCorasick) step(state int32, b byte) int32 {
+	for {
+		if next, ok := ac.states[state].next[b]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = ac.states[state].fail
+	}
+}
+
+// endsAt returns the end of every needle occurring exactly at start,
+// longest first, by walking the trie from the root.
+func (ac *ahoCorasick) endsAt(line []byte, start int) []int {
+	var ends []int
+	if ac.hasEmpty {
+		ends = append(ends, start)
+	}
+
+	state := int32(0)
+	for i := start; i < len(line); i++ {
+		next, ok := ac.states[state].next[line[i]]
+		if !ok {
+			break
+		}
+		state = next
+		for _, length := range ac.states[state].ends {
+			if length == ac.states[state].depth {
+				ends = append(ends, i+1)
+				break
+			}
+		}
+	}
+
+	slices.Reverse(ends)
+	return ends
+}
@@ -1,140 +1,110 @@
 package matcher
 
 import (
-	"container/list"
-	"strings"
+	"grep-go/internal/parsers"
 )
 
-func matchAlternation(runes []rune, pattern string, index int) (bool, int, error) {
-	// Remove "ALT:" prefix
+// indexThreshold is the number of alternatives from which an alternation
+// gets an alternationIndex. Below it, trying every alternative is cheap.
+const indexThreshold = 8
 
-	alternativesStr := pattern[4:]
-	alternatives := strings.Split(alternativesStr, "|")
-
-	// Try each alternative
-	for _, alt := range alternatives {
-		alt = strings.TrimSpace(alt)
-		if alt == "" {
-			continue
-		}
+// alternationIndex narrows the alternatives of a large alternation, such
+// as the one built from many -e / -f patterns, down to those that can
+// start with the next input character.
+type alternationIndex struct {
+	byRune map[rune][]*parsers.Node // candidates for each possible first character, in pattern order
+	other  []*parsers.Node          // alternatives without a fixed first character
+}
 
-		// Try to match this alternative as a complete substring
-		altRunes := []rune(alt)
-		if index+len(altRunes) <= len(runes) {
-			match := true
-			for j := 0; j < len(altRunes); j++ {
-				if runes[index+j] != altRunes[j] {
-					match = false
-					break
-				}
-			}
-			if match {
-				return true, index + len(altRunes), nil
+// matchAlternation tries each alternative in order and returns as soon
+// as one of them lets the rest of the pattern match. This gives
+// leftmost-first semantics; with leftmost-longest, the final
+// continuation never accepts, so every alternative gets tried.
+func (m *machine) matchAlternation(n *parsers.Node, pos int, k continuation) bool {
+	alternatives := n.Children
+
+	if index, ok := m.re.alternations[n]; ok {
+		alternatives = index.other
+		if pos < len(m.runes) {
+			if candidates, ok := index.byRune[m.runes[pos]]; ok {
+				alternatives = candidates
 			}
 		}
 	}
 
-	return false, -1, nil
+	for _, alt := range alternatives {
+		if m.match(alt, pos, k) {
+			return true
+		}
+	}
+	return false
 }
 
-func matchAlternationPlus(runes []rune, pattern string, index int, pat *list.Element) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
+// indexAlternations builds an alternationIndex for every large
+// alternation in the tree below n.
+func indexAlternations(n *parsers.Node, indexes map[*parsers.Node]*alternationIndex) {
+	if n.Kind == parsers.NodeAlternate && len(n.Children) >= indexThreshold {
+		indexes[n] = newAlternationIndex(n.Children)
 	}
+	for _, child := range n.Children {
+		indexAlternations(child, indexes)
+	}
+}
 
-	// Remove "ALT+:" prefix
-	alternativesStr := pattern[5:]
-	alternatives := strings.Split(alternativesStr, "|")
-
-	// Get the next pattern in the sequence
-	nextPat := pat.Next()
-
-	// Must match at least once
-	matched := false
-	currentPos := index
-
-	// Keep trying to match alternatives until no more matches
-	for {
-		foundMatch := false
-		bestPos := currentPos
-
-		// Try each alternative at current position
-		for _, alt := range alternatives {
-			alt = strings.TrimSpace(alt)
-			if alt == "" {
-				continue
-			}
-
-			altRunes := []rune(alt)
-			if currentPos+len(altRunes) <= len(runes) {
-				match := true
-				for j := 0; j < len(altRunes); j++ {
-					if runes[currentPos+j] != altRunes[j] {
-						match = false
-						break
-					}
-				}
-				if match {
-					bestPos = currentPos + len(altRunes)
-					foundMatch = true
-					matched = true
-					break // Use first matching alternative
-				}
-			}
-		}
-
-		if !foundMatch {
-			break
+// newAlternationIndex groups alternatives by their first character.
+// Alternatives that can start with anything are added to every group,
+// keeping the original order so leftmost-first semantics are unchanged.
+func newAlternationIndex(alternatives []*parsers.Node) *alternationIndex {
+	index := &alternationIndex{byRune: make(map[rune][]*parsers.Node)}
+
+	firsts := make([]rune, len(alternatives))
+	fixed := make([]bool, len(alternatives))
+	for i, alt := range alternatives {
+		firsts[i], fixed[i] = firstRune(alt)
+		if fixed[i] {
+			index.byRune[firsts[i]] = nil
+		} else {
+			index.other = append(index.other, alt)
 		}
+	}
 
-		// If we have a next pattern, try to match it from various positions
-		if nextPat != nil {
-			// Try to match remaining pattern from this position
-			for testPos := bestPos; testPos <= len(runes); testPos++ {
-				if matchPatternsFromPosition(runes, nextPat, testPos) {
-					return true, testPos, nil
-				}
+	for r := range index.byRune {
+		var candidates []*parsers.Node
+		for i, alt := range alternatives {
+			if !fixed[i] || firsts[i] == r {
+				candidates = append(candidates, alt)
 			}
 		}
-
-		currentPos = bestPos
-	}
-
-	if !matched {
-		return false, -1, nil
+		index.byRune[r] = candidates
 	}
 
-	// If no next pattern, we're done
-	return true, currentPos, nil
+	return index
 }
 
-func matchAlternationOptional(runes []rune, pattern string, index int) (bool, int, error) {
-	// Remove "ALT?:" prefix
-	alternativesStr := pattern[5:]
-	alternatives := strings.Split(alternativesStr, "|")
+// firstRune returns the character every match of n must start with, if
+// there is a single such character.
+func firstRune(n *parsers.Node) (rune, bool) {
+	switch n.Kind {
+	case parsers.NodeLiteral:
+		return n.Runes[0], true
 
-	// Try each alternative
-	for _, alt := range alternatives {
-		alt = strings.TrimSpace(alt)
-		if alt == "" {
-			continue
+	case parsers.NodeGroup:
+		return firstRune(n.Children[0])
+
+	case parsers.NodeRepeat:
+		if n.Min > 0 {
+			return firstRune(n.Children[0])
 		}
 
-		altRunes := []rune(alt)
-		if index+len(altRunes) <= len(runes) {
-			match := true
-			for j := 0; j < len(altRunes); j++ {
-				if runes[index+j] != altRunes[j] {
-					match = false
-					break
-				}
-			}
-			if match {
-				return true, index + len(altRunes), nil
+	case parsers.NodeConcat:
+		for _, child := range n.Children {
+			// Anchors do not consume anything, look past them
+			if child.Kind == parsers.NodeLineStart || child.Kind == parsers.NodeLineEnd {
+				continue
 			}
+			return firstRune(child)
 		}
 	}
 
-	// Optional means it's okay if no alternative matches
-	return true, index, nil
+	return 0, false
 }
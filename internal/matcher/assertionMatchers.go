@@ -0,0 +1,98 @@
+package matcher
+
+import (
+	"grep-go/internal/parsers"
+)
+
+// matchLookaround checks a lookahead or lookbehind assertion at pos
+// without consuming anything. Groups captured inside a positive
+// assertion stay set for the rest of the pattern; the assertion itself
+// is never backtracked into.
+func (m *machine) matchLookaround(n *parsers.Node, pos int, k continuation) bool {
+	saved := m.save()
+
+	var found bool
+	if n.Kind == parsers.NodeLookahead {
+		found = m.match(n.Children[0], pos, func(int) bool { return true })
+	} else {
+		// Try the closest start first; any length is allowed
+		for from := pos; from >= 0 && !found; from-- {
+			found = m.match(n.Children[0], from, func(end int) bool { return end == pos })
+		}
+	}
+	// \K inside an assertion does not move the match start
+	m.start = saved.start
+
+	if found == n.Negated {
+		m.restore(saved)
+		return false
+	}
+	if n.Negated {
+		m.restore(saved)
+	}
+
+	if k(pos) {
+		return true
+	}
+	m.restore(saved)
+	return false
+}
+
+// matchAtomic matches an atomic group: only the first way its content
+// matches is kept, and the rest of the pattern cannot backtrack into it.
+func (m *machine) matchAtomic(n *parsers.Node, pos int, k continuation) bool {
+	saved := m.save()
+
+	end := -1
+	if !m.match(n.Children[0], pos, func(p int) bool {
+		end = p
+		return true
+	}) {
+		return false
+	}
+
+	if k(end) {
+		return true
+	}
+	m.restore(saved)
+	return false
+}
+
+// matchKeep moves the start of the reported match to pos (\K).
+func (m *machine) matchKeep(pos int, k continuation) bool {
+	oldStart := m.start
+	m.start = pos
+
+	if k(pos) {
+		return true
+	}
+
+	m.start = oldStart
+	return false
+}
+
+// atWordBoundary reports whether pos lies between a word character and
+// a non-word character, the ends of the line counting as non-word.
+func (m *machine) atWordBoundary(pos int) bool {
+	before := pos > 0 && IsAlphanumeric(m.runes[pos-1])
+	after := pos < len(m.runes) && IsAlphanumeric(m.runes[pos])
+	return before != after
+}
+
+// machineState is a copy of the captures and match start of a machine,
+// restored when an assertion or atomic group is backtracked over.
+type machineState struct {
+	caps  []int
+	start int
+}
+
+func (m *machine) save() machineState {
+	caps := make([]int, len(m.caps))
+	copy(caps, m.caps)
+	return machineState{caps: caps, start: m.start}
+}
+
+func (m *machine) restore(saved machineState) {
+	copy(m.caps, saved.caps)
+	m.start = saved.start
+}
@@ -1,324 +1,273 @@
 package matcher
 
 import (
-	"container/list"
-	"fmt"
+	"encoding/binary"
+	"grep-go/internal/parsers"
 )
 
-func matchPatternsFromPosition(runes []rune, patterns *list.Element, startPos int) bool {
-	currentPos := startPos
-	end_detect := false
-
-	for pat := patterns; pat != nil; pat = pat.Next() {
-		pat_string := pat.Value.(string)
-		end_detect = false
-
-		if len(pat_string) > 0 && pat_string[len(pat_string)-1] == '$' {
-			pat_string = pat_string[0 : len(pat_string)-1]
-			end_detect = true
-		}
+// continuation is called with the position reached after a node matched.
+// It returns true if the rest of the pattern matched from that position;
+// returning false makes the caller backtrack and try its next option.
+type continuation func(pos int) bool
+
+// machine holds the state of a single backtracking match over a line.
+type machine struct {
+	re    *Regexp
+	runes []rune
+	caps  []int // start/end rune index of every capture group, -1 if unset
+	start int   // rune index where the reported match starts, moved by \K
+
+	// longest selects leftmost-longest semantics for matchAt. It is
+	// cleared when only the presence of a match matters.
+	longest bool
+
+	// Leftmost-longest semantics: the best match found so far from the
+	// current start position, bestEnd being -1 before the first one
+	best    machineState
+	bestEnd int
+
+	// Leftmost-longest semantics: the repeat states already explored
+	// from the current start position, and the start positions of the
+	// groups and repeat iterations being matched, which together with
+	// the node decide what the rest of the pattern can do
+	visited map[memoKey]bool
+	frames  []int
+}
 
-		// Handle empty pattern after removing $
-		if len(pat_string) == 0 {
-			if end_detect {
-				// This is just a $ anchor, check if we're at end of input
-				input_text_length := len(runes)
-				if currentPos == input_text_length {
-					return true
-				} else {
-					return false
-				}
-			} else {
-				// Empty pattern without $ - this shouldn't happen, skip it
-				continue
-			}
-		}
+// memoKey identifies a repeat state: every way of going on from it
+// leads to the same matches, with the same captures.
+type memoKey struct {
+	node  *parsers.Node
+	pos   int
+	count int
+	state string // captures, \K start and frames
+}
 
-		found, nextPos, err := matchIndividualPattern(runes, pat_string, currentPos, pat)
+func newMachine(re *Regexp, runes []rune) *machine {
+	return &machine{
+		re:      re,
+		runes:   runes,
+		caps:    make([]int, 2*(re.pattern.Groups+1)),
+		longest: re.opts.Semantics == LeftmostLongest,
+		best:    machineState{caps: make([]int, 2*(re.pattern.Groups+1))},
+	}
+}
 
-		if err != nil {
-			return false
-		}
+// matchAt tries to match the whole pattern starting exactly at pos.
+// A match ending where -w or -x do not allow it is rejected by the final
+// continuation, which makes the matcher backtrack for another one.
+//
+// With leftmost-longest semantics the final continuation records the
+// match and rejects it anyway, so every way of matching from pos is
+// tried and the best one is kept. Repeat states already explored are
+// not explored again, which keeps patterns such as "(a|a)*" from
+// taking exponential time.
+//
+// Returns:
+//   - int:  rune index where the match starts: pos, or the position of
+//     the last \K passed
+//   - int:  rune index just past the end of the match
+//   - bool: whether the pattern matched at pos
+func (m *machine) matchAt(pos int) (int, int, bool) {
+	if !m.re.opts.acceptStart(m.runes, pos) {
+		return -1, -1, false
+	}
 
-		currentPos = nextPos
+	for i := range m.caps {
+		m.caps[i] = -1
+	}
+	m.start = pos
 
-		if end_detect {
-			// handle string anchor for string end
-			input_text_length := len(runes)
-			if found && input_text_length == currentPos {
-				return true
-			} else {
-				return false
-			}
-		}
+	if m.longest {
+		return m.matchLongestAt(pos)
+	}
 
-		if !found {
+	end := -1
+	found := m.match(m.re.pattern.Root, pos, func(p int) bool {
+		if !m.re.opts.acceptEnd(m.runes, p) {
 			return false
 		}
-	}
+		end = p
+		return true
+	})
 
-	return true
+	return m.start, end, found
 }
 
-func matchIndividualPattern(runes []rune, pattern string, index int, pat *list.Element) (bool, int, error) {
-	if len(pattern) == 0 {
-		return false, -1, fmt.Errorf("empty pattern encountered @index: %d", index)
-	}
-	if index > len(runes) {
-		return false, -1, nil
-	}
-	if index == -1 {
-		return false, -1, fmt.Errorf("invalid index detected: %d", index)
+// matchLongestAt is matchAt for leftmost-longest semantics. On success
+// the captures of the machine are those of the match reported.
+func (m *machine) matchLongestAt(pos int) (int, int, bool) {
+	m.bestEnd = -1
+	if m.visited == nil {
+		m.visited = make(map[memoKey]bool)
 	}
+	clear(m.visited)
+	m.frames = m.frames[:0]
 
-	switch {
-	case pattern == "\\w":
-		return matchSingleCharacter(runes, IsAlphanumeric, index)
-
-	case pattern == "\\d":
-		return matchSingleCharacter(runes, IsDigit, index)
-
-	case pattern == "\\\\d":
-		// literal substring "\d"
-		return matchCompleteSubString(runes, `\d`, index)
-
-	case pattern == "\\\\w":
-		// literal substring "\w"
-		return matchCompleteSubString(runes, `\w`, index)
-
-	case pattern == ".+":
-		// Handle .+ pattern (one or more of any character)
-		return matchDotPlusBacktracking(runes, index, pat)
-
-	case len(pattern) > 4 && pattern[:4] == "ALT:":
-		// Handle simple alternation pattern
-		return matchAlternation(runes, pattern, index)
-
-	case len(pattern) > 5 && pattern[:4] == "ALT+":
-		// Handle alternation with + quantifier
-		return matchAlternationPlus(runes, pattern, index, pat)
-
-	case len(pattern) > 5 && pattern[:4] == "ALT?":
-		// Handle alternation with ? quantifier
-		return matchAlternationOptional(runes, pattern, index)
-
-	case len(pattern) > 4 && pattern[:4] == "GRP:":
-		// Handle simple group
-		return matchGroup(runes, pattern, index)
-
-	case len(pattern) > 5 && pattern[:4] == "GRP+":
-		// Handle group with + quantifier
-		return matchGroupPlus(runes, pattern, index, pat)
-
-	case len(pattern) > 5 && pattern[:4] == "GRP?":
-		// Handle group with ? quantifier
-		return matchGroupOptional(runes, pattern, index)
-
-	case pattern[0] == '.':
-		return matchWildCard(runes, index)
-	case pattern[0] == '+':
-		// matching +
-		return matchOneOrMoreBacktracking(runes, pattern, index, pat)
-
-	case pattern[0] == '?':
-		return matchOneOrNone(runes, pattern, index)
-
-	case len(pattern) > 0 && pattern[0] == '[':
-		return matchCharacterClass(runes, pattern, index)
+	m.match(m.re.pattern.Root, pos, func(p int) bool {
+		if !m.re.opts.acceptEnd(m.runes, p) {
+			return false
+		}
+		if p > m.bestEnd || (p == m.bestEnd && m.posixPreferred()) {
+			m.bestEnd = p
+			copy(m.best.caps, m.caps)
+			m.best.start = m.start
+		}
+		// Without groups to compare, nothing beats a match reaching the
+		// end of the line
+		return p == len(m.runes) && m.re.pattern.Groups == 0
+	})
 
-	default:
-		// Literal substring match
-		var b, i, r = matchCompleteSubString(runes, pattern, index)
-		return b, i, r
+	if m.bestEnd < 0 {
+		return -1, -1, false
 	}
+	m.restore(m.best)
+	return m.start, m.bestEnd, true
 }
 
-func matchDotPlusBacktracking(runes []rune, index int, pat *list.Element) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
+// explored reports whether the repeat state of n at pos, after count
+// iterations, was already explored from the current start position,
+// and marks it as explored. It is always false without memoization.
+func (m *machine) explored(n *parsers.Node, pos int, count int) bool {
+	if m.visited == nil || !m.longest {
+		return false
 	}
 
-	// .+ must match at least one character
-	if index >= len(runes) {
-		return false, -1, nil
+	state := make([]byte, 0, 2*(len(m.caps)+len(m.frames)+1))
+	for _, v := range m.caps {
+		state = binary.AppendVarint(state, int64(v))
 	}
-
-	// Get the next pattern in the sequence
-	nextPat := pat.Next()
-
-	// If this is the last pattern, match all remaining characters
-	if nextPat == nil {
-		return true, len(runes), nil
+	state = binary.AppendVarint(state, int64(m.start))
+	for _, v := range m.frames {
+		state = binary.AppendVarint(state, int64(v))
 	}
 
-	// Try matching from the longest possible match down to the minimum (1 character)
-	// This implements greedy matching with backtracking
-	for endPos := len(runes); endPos > index; endPos-- {
-		// Try to match the rest of the pattern from this position
-		if matchPatternsFromPosition(runes, nextPat, endPos) {
-			return true, endPos, nil
-		}
+	key := memoKey{node: n, pos: pos, count: iterations(n, count), state: string(state)}
+	if m.visited[key] {
+		return true
 	}
-
-	// If no match found with remaining patterns, just consume one character
-	return true, index + 1, nil
+	m.visited[key] = true
+	return false
 }
 
-func matchWildCard(runes []rune, index int) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, fmt.Errorf("index out of bounds for wildcard match")
+// pushFrame and popFrame maintain the frames of memoization around the
+// content of groups and repeat iterations. They do nothing without it.
+func (m *machine) pushFrame(values ...int) {
+	if m.longest {
+		m.frames = append(m.frames, values...)
 	}
-	return true, index + 1, nil
 }
 
-func matchOneOrNone(runes []rune, pattern string, index int) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
-	}
-
-	// Remove the + to get the character/pattern to match
-	basePattern := pattern[1:]
-
-	// Handle different base patterns
-	var matches func(rune) bool
-
-	if basePattern == "\\d" {
-		matches = IsDigit
-	} else if basePattern == "\\w" {
-		matches = IsAlphanumeric
-	} else if len(basePattern) == 1 {
-		// Single character
-		char := rune(basePattern[0])
-		matches = func(r rune) bool { return r == char }
-	} else {
-		// For more multi character pattern, custom implementation is needed
-		return false, -1, fmt.Errorf("unsupported pattern with +: %s", pattern)
-	}
-
-	// case when no occourance is detected
-	if !matches(runes[index]) {
-		return true, index, nil
-	} else {
-		return true, index + 1, nil
+func (m *machine) popFrame(size int) {
+	if m.longest {
+		m.frames = m.frames[:len(m.frames)-size]
 	}
 }
 
-func matchOneOrMoreBacktracking(runes []rune, pattern string, index int, pat *list.Element) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
+// posixPreferred reports whether the current captures are preferred by
+// POSIX over those of the best match of the same length: the first
+// group that differs decides, a group that took part beating one that
+// did not, then the earlier start, then the longer span.
+func (m *machine) posixPreferred() bool {
+	for i := 2; i < len(m.caps); i += 2 {
+		start, end := m.caps[i], m.caps[i+1]
+		bestStart, bestEnd := m.best.caps[i], m.best.caps[i+1]
+
+		switch {
+		case start == bestStart && end == bestEnd:
+			continue
+		case bestStart < 0:
+			return true
+		case start < 0:
+			return false
+		case start != bestStart:
+			return start < bestStart
+		}
+		return end > bestEnd
 	}
+	return false
+}
 
-	// Remove the + to get the character/pattern to match
-	basePattern := pattern[1:]
-
-	// Handle different base patterns
-	var matches func(rune) bool
-
-	if basePattern == "\\d" {
-		matches = IsDigit
-	} else if basePattern == "\\w" {
-		matches = IsAlphanumeric
-	} else if len(basePattern) == 1 {
-		// Single character
-		char := rune(basePattern[0])
-		matches = func(r rune) bool { return r == char }
-	} else {
-		// For more multi character pattern, custom implementation is needed
-		return false, -1, fmt.Errorf("unsupported pattern with +: %s", pattern)
-	}
+// match matches a single node at pos and passes every position it can
+// reach to k, in order of preference, until k accepts one.
+func (m *machine) match(n *parsers.Node, pos int, k continuation) bool {
+	switch n.Kind {
+	case parsers.NodeEmpty:
+		return k(pos)
 
-	// case when no occourance is detected
-	if !matches(runes[index]) {
-		return false, -1, nil
-	}
+	case parsers.NodeLiteral:
+		return m.matchLiteral(n.Runes, pos, k)
 
-	// Match as many as possible while looking ahead
-	i := index
-	pat = pat.Next()
+	case parsers.NodeAnyChar:
+		return m.matchSingleCharacter(func(rune) bool { return true }, pos, k)
 
-	for i < len(runes) && matches(runes[i]) { // this only checks for overlapping queries
-		if matchPatternsFromPosition(runes, pat, i) {
-			return true, i, nil
-		}
-		i++
-	}
+	case parsers.NodeClass:
+		return m.matchSingleCharacter(func(r rune) bool { return inClass(n.Class, r) }, pos, k)
 
-	// this is in case there is no overlap
-	return true, index + 1, nil
-}
+	case parsers.NodeLineStart:
+		return pos == 0 && k(pos)
 
-func matchCharacterClass(runes []rune, pattern string, index int) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
-	}
+	case parsers.NodeLineEnd:
+		return pos == len(m.runes) && k(pos)
 
-	// Parse character class [abc] or [^abc]
-	negated := false
-	chars := pattern[1 : len(pattern)-1] // remove [ and ]
+	case parsers.NodeConcat:
+		return m.matchSequence(n.Children, pos, k)
 
-	if len(chars) > 0 && chars[0] == '^' {
-		negated = true
-		chars = chars[1:]
-	}
+	case parsers.NodeAlternate:
+		return m.matchAlternation(n, pos, k)
 
-	targetChar := runes[index]
-	found := false
+	case parsers.NodeGroup:
+		return m.matchGroup(n, pos, k)
 
-	// Check if character is in the class
-	for _, char := range chars {
-		if targetChar == char {
-			found = true
-			break
-		}
-	}
+	case parsers.NodeRepeat:
+		return m.matchRepeat(n, pos, 0, k)
 
-	if negated {
-		found = !found
-	}
+	case parsers.NodeBackref:
+		return m.matchBackref(n.Index, pos, k)
 
-	if found {
-		return true, index + 1, nil
-	}
+	case parsers.NodeLookahead, parsers.NodeLookbehind:
+		return m.matchLookaround(n, pos, k)
 
-	return false, -1, nil
-}
+	case parsers.NodeAtomic:
+		return m.matchAtomic(n, pos, k)
 
-func matchSingleCharacter(runes []rune, predicate func(rune) bool, index int) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
-	}
+	case parsers.NodeKeep:
+		return m.matchKeep(pos, k)
 
-	if predicate(runes[index]) {
-		return true, index + 1, nil
+	case parsers.NodeWordBoundary:
+		return m.atWordBoundary(pos) != n.Negated && k(pos)
 	}
 
-	return false, -1, nil
+	return false
 }
 
-func matchCompleteSubString(runes []rune, pattern string, index int) (bool, int, error) {
-	patRunes := []rune(pattern)
-
-	if index+len(patRunes) > len(runes) && !(patRunes[len(patRunes)-1] == '$' && index+len(patRunes)-1 == len(runes)) { // sprcifically checking if the last char is not $
-		return false, -1, nil
+// matchSingleCharacter consumes one character accepted by predicate.
+func (m *machine) matchSingleCharacter(predicate func(rune) bool, pos int, k continuation) bool {
+	if pos >= len(m.runes) || !predicate(m.runes[pos]) {
+		return false
 	}
+	return k(pos + 1)
+}
 
-	if patRunes[len(patRunes)-1] == '$' {
-		if index+len(patRunes)-1 != len(runes) {
-			return false, -1, nil
-		}
-		for j := 0; j < len(patRunes)-1; j++ {
-			if runes[index+j] != patRunes[j] {
-				return false, -1, nil
-			}
-		}
-		return true, index + len(pattern), nil
+// matchLiteral consumes the exact text of a literal node.
+func (m *machine) matchLiteral(text []rune, pos int, k continuation) bool {
+	if pos+len(text) > len(m.runes) {
+		return false
 	}
-
-	for j := 0; j < len(patRunes); j++ {
-		if runes[index+j] != patRunes[j] {
-			return false, -1, nil
+	for j, r := range text {
+		if m.runes[pos+j] != r {
+			return false
 		}
 	}
+	return k(pos + len(text))
+}
 
-	return true, index + len(patRunes), nil
+// matchBackref consumes the text last captured by group index. A group
+// that did not take part in the match makes the reference fail.
+func (m *machine) matchBackref(index int, pos int, k continuation) bool {
+	start, end := m.caps[2*index], m.caps[2*index+1]
+	if start < 0 || end < 0 {
+		return false
+	}
+	return m.matchLiteral(m.runes[start:end], pos, k)
 }
@@ -1,128 +1,99 @@
 package matcher
 
 import (
-	"container/list"
 	"grep-go/internal/parsers"
 )
 
-func matchGroup(runes []rune, pattern string, index int) (bool, int, error) {
-	// Remove "GRP:" prefix and parse the group content
-	groupContent := pattern[4:]
-	parser := parsers.NewParser()
-	groupPatterns, err := parser.ParsePatterns(groupContent)
-
-	if err != nil {
-		return false, -1, err
+// matchSequence matches nodes one after another, backtracking into
+// earlier nodes when a later one fails.
+func (m *machine) matchSequence(nodes []*parsers.Node, pos int, k continuation) bool {
+	if len(nodes) == 0 {
+		return k(pos)
 	}
+	return m.match(nodes[0], pos, func(next int) bool {
+		return m.matchSequence(nodes[1:], next, k)
+	})
+}
 
-	// Match all patterns in the group sequentially
-	currentPos := index
-	for groupPat := groupPatterns.Front(); groupPat != nil; groupPat = groupPat.Next() {
-		patStr := groupPat.Value.(string)
-		found, nextPos, err := matchIndividualPattern(runes, patStr, currentPos, groupPat)
-		if err != nil || !found {
-			return false, -1, err
+// matchGroup matches the content of a capture group and records the
+// captured span while the rest of the pattern is tried. The previous
+// span is restored if the rest fails.
+func (m *machine) matchGroup(n *parsers.Node, pos int, k continuation) bool {
+	slot := 2 * n.Index
+
+	// The content of the group goes on differently depending on where
+	// the group started
+	m.pushFrame(pos)
+	found := m.match(n.Children[0], pos, func(end int) bool {
+		m.popFrame(1)
+		oldStart, oldEnd := m.caps[slot], m.caps[slot+1]
+		m.caps[slot], m.caps[slot+1] = pos, end
+
+		if k(end) {
+			return true
 		}
-		currentPos = nextPos
-	}
 
-	return true, currentPos, nil
+		m.caps[slot], m.caps[slot+1] = oldStart, oldEnd
+		m.pushFrame(pos)
+		return false
+	})
+	if !found {
+		m.popFrame(1)
+	}
+	return found
 }
 
-func matchGroupPlus(runes []rune, pattern string, index int, pat *list.Element) (bool, int, error) {
-	if index >= len(runes) {
-		return false, -1, nil
+// matchRepeat matches n.Children[0] between n.Min and n.Max times.
+// Greedy repeats try one more iteration before giving up, lazy repeats
+// try to stop first. An iteration that consumes nothing once the
+// minimum is reached ends the loop, so "(a*)*" cannot spin forever.
+//
+// Params:
+//   - count: number of iterations already matched
+func (m *machine) matchRepeat(n *parsers.Node, pos int, count int, k continuation) bool {
+	if m.explored(n, pos, count) {
+		return false
 	}
 
-	// Remove "GRP+:" prefix
-	groupContent := pattern[5:]
-
-	// Get the next pattern in the sequence
-	nextPat := pat.Next()
-
-	// Collect all possible match positions for backtracking
-	var matchPositions []int
-	currentPos := index
-
-	// Keep trying to match the group and collect all valid positions
-	for {
-		parser := parsers.NewParser()
-		groupPatterns, err := parser.ParsePatterns(groupContent)
-
-		if err != nil {
-			return false, -1, err
+	more := func() bool {
+		if n.Max != parsers.Unbounded && count >= n.Max {
+			return false
 		}
 
-		// Try to match all patterns in the group
-		tempPos := currentPos
-		groupMatched := true
-
-		for groupPat := groupPatterns.Front(); groupPat != nil; groupPat = groupPat.Next() {
-			patStr := groupPat.Value.(string)
-			found, nextPos, err := matchIndividualPattern(runes, patStr, tempPos, groupPat)
-			if err != nil || !found {
-				groupMatched = false
-				break
+		// The iteration goes on differently depending on its count and
+		// where it started
+		m.pushFrame(iterations(n, count), pos)
+		found := m.match(n.Children[0], pos, func(next int) bool {
+			m.popFrame(2)
+			if next != pos || count < n.Min {
+				if m.matchRepeat(n, next, count+1, k) {
+					return true
+				}
 			}
-			tempPos = nextPos
-		}
-
-		if !groupMatched {
-			break
+			m.pushFrame(iterations(n, count), pos)
+			return false
+		})
+		if !found {
+			m.popFrame(2)
 		}
-
-		// Record this as a valid match position
-		matchPositions = append(matchPositions, tempPos)
-		currentPos = tempPos
+		return found
 	}
 
-	// Must match at least once
-	if len(matchPositions) == 0 {
-		return false, -1, nil
+	if count < n.Min {
+		return more()
 	}
-
-	// If no next pattern, return the last (greedy) match
-	if nextPat == nil {
-		return true, matchPositions[len(matchPositions)-1], nil
+	if n.Greedy {
+		return more() || k(pos)
 	}
-
-	// Try backtracking: start from the longest match and work backwards
-	for i := len(matchPositions) - 1; i >= 0; i-- {
-		pos := matchPositions[i]
-
-		if matchPatternsFromPosition(runes, nextPat, pos) {
-			return true, pos, nil
-		}
-	}
-
-	// No backtracking position worked
-	return false, -1, nil
+	return k(pos) || more()
 }
 
-func matchGroupOptional(runes []rune, pattern string, index int) (bool, int, error) {
-	// Remove "GRP?:" prefix
-	groupContent := pattern[5:]
-
-	// Parse the group content into patterns
-	parser := parsers.NewParser()
-	groupPatterns, err := parser.ParsePatterns(groupContent)
-
-	if err != nil {
-		return true, index, nil
-	}
-
-	// Try to match all patterns in the group sequentially
-	currentPos := index
-	for groupPat := groupPatterns.Front(); groupPat != nil; groupPat = groupPat.Next() {
-		patStr := groupPat.Value.(string)
-
-		found, nextPos, err := matchIndividualPattern(runes, patStr, currentPos, groupPat)
-		if err != nil || !found {
-			// Optional group doesn't match, that's okay
-			return true, index, nil
-		}
-		currentPos = nextPos
+// iterations returns the count of a repeat as far as matching goes:
+// once n.Min is reached, the count of an unbounded repeat no longer
+// changes what it can match.
+func iterations(n *parsers.Node, count int) int {
+	if n.Max == parsers.Unbounded {
+		return min(count, n.Min)
 	}
-
-	return true, currentPos, nil
+	return count
 }
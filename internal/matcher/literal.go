@@ -0,0 +1,190 @@
+package matcher
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Matcher finds the matches of the search patterns in a line. It is
+// implemented by Regexp and by the fixed string matchers of -F, so the
+// search and output layers work the same for both.
+type Matcher interface {
+	// Match reports whether the line contains a match.
+	Match(line []byte) bool
+
+	// FindAt finds the leftmost match starting at or after byte offset
+	// start, returning its [start, end) byte offsets.
+	FindAt(line []byte, start int) (int, int, bool)
+
+	// FindAll returns the byte spans of all successive non-overlapping
+	// matches in the line.
+	FindAll(line []byte) [][2]int
+}
+
+// CompileFixed builds a Matcher for fixed strings (-F): the needles are
+// searched for literally, without going through the regex parser. A
+// single needle uses a plain substring search, several needles share an
+// Aho-Corasick automaton so every line is scanned once. An empty needle
+// matches every line.
+func CompileFixed(needles []string, opts Options) Matcher {
+	if len(needles) == 1 {
+		return &literalMatcher{needle: []byte(needles[0]), opts: opts}
+	}
+	return newAhoCorasick(needles, opts)
+}
+
+// fixedSearcher is the raw search of a fixed string matcher, before the
+// -w and -x restrictions are applied by findFixed.
+type fixedSearcher interface {
+	// find returns the leftmost-longest needle occurrence starting at or
+	// after start.
+	find(line []byte, start int) (int, int, bool)
+
+	// endsAt returns the end offsets of every needle occurring exactly
+	// at start, longest first.
+	endsAt(line []byte, start int) []int
+}
+
+// findFixed finds the leftmost match of a fixed string search allowed by
+// opts. When the leftmost-longest occurrence is not a whole word (-w),
+// the shorter needles occurring at the same place are tried, then the
+// search moves on to later occurrences.
+func findFixed(s fixedSearcher, opts Options, line []byte, start int) (int, int, bool) {
+	if !opts.WordMatch && !opts.LineMatch {
+		return s.find(line, start)
+	}
+
+	if opts.LineMatch {
+		// Only a needle equal to the whole line can match
+		if start == 0 {
+			for _, end := range s.endsAt(line, 0) {
+				if end == len(line) {
+					return 0, end, true
+				}
+			}
+		}
+		return -1, -1, false
+	}
+
+	for pos := start; pos <= len(line); {
+		matchStart, _, ok := s.find(line, pos)
+		if !ok {
+			return -1, -1, false
+		}
+
+		if wordBoundaryBefore(line, matchStart) {
+			for _, end := range s.endsAt(line, matchStart) {
+				if wordBoundaryAfter(line, end) {
+					return matchStart, end, true
+				}
+			}
+		}
+
+		pos = nextChar(line, matchStart)
+	}
+
+	return -1, -1, false
+}
+
+// wordBoundaryBefore reports whether byte offset i is the start of the
+// line or follows a non-word character.
+func wordBoundaryBefore(line []byte, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRune(line[:i])
+	return !IsAlphanumeric(r)
+}
+
+// wordBoundaryAfter reports whether byte offset i is the end of the line
+// or is followed by a non-word character.
+func wordBoundaryAfter(line []byte, i int) bool {
+	if i >= len(line) {
+		return true
+	}
+	r, _ := utf8.DecodeRune(line[i:])
+	return !IsAlphanumeric(r)
+}
+
+// literalMatcher searches for a single fixed string.
+type literalMatcher struct {
+	needle []byte
+	opts   Options
+}
+
+func (l *literalMatcher) Match(line []byte) bool {
+	if !l.opts.WordMatch && !l.opts.LineMatch {
+		return bytes.Contains(line, l.needle)
+	}
+	_, _, found := l.FindAt(line, 0)
+	return found
+}
+
+func (l *literalMatcher) FindAt(line []byte, start int) (int, int, bool) {
+	return findFixed(l, l.opts, line, start)
+}
+
+func (l *literalMatcher) FindAll(line []byte) [][2]int {
+	return findAll(line, l.FindAt)
+}
+
+func (l *literalMatcher) find(line []byte, start int) (int, int, bool) {
+	if start > len(line) {
+		return -1, -1, false
+	}
+	i := bytes.Index(line[start:], l.needle)
+	if i < 0 {
+		return -1, -1, false
+	}
+	return start + i, start + i + len(l.needle), true
+}
+
+func (l *literalMatcher) endsAt(line []byte, start int) []int {
+	if bytes.HasPrefix(line[start:], l.needle) {
+		return []int{start + len(l.needle)}
+	}
+	return nil
+}
+
+// findAll collects every non-overlapping match found by successive calls
+// to find, with the same handling of empty matches as Regexp.FindAll: an
+// empty match right after the previous match is skipped, and the search
+// moves forward by one character after an empty match.
+func findAll(line []byte, find func(line []byte, start int) (int, int, bool)) [][2]int {
+	var spans [][2]int
+	prevEnd := -1
+
+	for pos := 0; pos <= len(line); {
+		start, end, ok := find(line, pos)
+		if !ok {
+			break
+		}
+
+		if start == end && start == prevEnd {
+			// Empty match adjacent to the previous one: not a new match
+			pos = nextChar(line, start)
+			continue
+		}
+
+		spans = append(spans, [2]int{start, end})
+		prevEnd = end
+
+		if end > start {
+			pos = end
+		} else {
+			pos = nextChar(line, end)
+		}
+	}
+
+	return spans
+}
+
+// nextChar returns the byte offset of the character following the one
+// at offset i, or len(line)+1 at the end of the line.
+func nextChar(line []byte, i int) int {
+	if i >= len(line) {
+		return len(line) + 1
+	}
+	_, size := utf8.DecodeRune(line[i:])
+	return i + size
+}
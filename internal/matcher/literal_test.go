@@ -0,0 +1,58 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompileFixed(t *testing.T) {
+	word := Options{WordMatch: true}
+	line := Options{LineMatch: true}
+
+	tests := []struct {
+		name    string
+		needles []string
+		opts    Options
+		line    string
+		want    [][2]int // FindAll spans
+	}{
+		// One needle: plain substring search
+		{"single", []string{"ab"}, Options{}, "xabyab", [][2]int{{1, 3}, {4, 6}}},
+		{"single no match", []string{"ab"}, Options{}, "xyz", nil},
+		{"single non-overlapping", []string{"aa"}, Options{}, "aaaa", [][2]int{{0, 2}, {2, 4}}},
+		{"single empty", []string{""}, Options{}, "ab", [][2]int{{0, 0}, {1, 1}, {2, 2}}},
+		{"single word", []string{"foo"}, word, "foobar foo", [][2]int{{7, 10}}},
+		{"single line", []string{"foo"}, line, "foo", [][2]int{{0, 3}}},
+		{"single line rejected", []string{"foo"}, line, "foo ", nil},
+		{"single empty line", []string{""}, line, "", [][2]int{{0, 0}}},
+		{"single empty line rejected", []string{""}, line, "a", nil},
+
+		// Many needles: Aho-Corasick, leftmost then longest
+		{"overlapping", []string{"abc", "bcd"}, Options{}, "abcd", [][2]int{{0, 3}}},
+		{"overlapping reversed", []string{"bcd", "abc"}, Options{}, "abcd", [][2]int{{0, 3}}},
+		{"prefix", []string{"ab", "abcd"}, Options{}, "abcde abx", [][2]int{{0, 4}, {6, 8}}},
+		{"failure links", []string{"he", "she", "hers", "his"}, Options{}, "ushers", [][2]int{{1, 4}}},
+		{"longer needle fails", []string{"abcx", "bc"}, Options{}, "abcy", [][2]int{{1, 3}}},
+		{"earlier longer needle", []string{"abcd", "bc"}, Options{}, "abcd", [][2]int{{0, 4}}},
+		{"no match", []string{"foo", "bar"}, Options{}, "baz fo", nil},
+		{"empty needle", []string{"", "ab"}, Options{}, "xab", [][2]int{{0, 0}, {1, 3}}},
+		{"word longest", []string{"foo", "foobar"}, word, "foobar foo", [][2]int{{0, 6}, {7, 10}}},
+		{"word shorter", []string{"ab", "ab c"}, word, "ab cd", [][2]int{{0, 2}}},
+		{"word later", []string{"ab", "abc"}, word, "abcd ab", [][2]int{{5, 7}}},
+		{"line longest", []string{"foo", "foobar"}, line, "foobar", [][2]int{{0, 6}}},
+		{"line shorter", []string{"foo", "foobar"}, line, "foo", [][2]int{{0, 3}}},
+		{"line rejected", []string{"foo", "foobar"}, line, "foob", nil},
+		{"line empty needle", []string{"", "x"}, line, "", [][2]int{{0, 0}}},
+	}
+
+	for _, tt := range tests {
+		m := CompileFixed(tt.needles, tt.opts)
+
+		if got := m.FindAll([]byte(tt.line)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FindAll(%q) with %q = %v, want %v", tt.name, tt.line, tt.needles, got, tt.want)
+		}
+		if got := m.Match([]byte(tt.line)); got != (tt.want != nil) {
+			t.Errorf("%s: Match(%q) with %q = %v, want %v", tt.name, tt.line, tt.needles, got, tt.want != nil)
+		}
+	}
+}
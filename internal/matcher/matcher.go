@@ -2,12 +2,86 @@ package matcher
 
 import (
 	"grep-go/internal/parsers"
+	"unicode/utf8"
 )
 
+// Regexp is a compiled pattern that can be matched against many lines
+// without being parsed again.
+type Regexp struct {
+	pattern      *parsers.Pattern
+	alternations map[*parsers.Node]*alternationIndex
+	opts         Options
+}
+
+// Options controls how patterns are matched. It applies to regular
+// expressions and fixed strings alike.
+type Options struct {
+	// WordMatch only accepts matches that are whole words (-w): preceded
+	// by the start of the line or a non-word character, and followed by
+	// the end of the line or a non-word character.
+	WordMatch bool
+
+	// LineMatch only accepts matches spanning the whole line (-x).
+	LineMatch bool
+
+	// Syntax is the dialect regular expressions are written in. It is
+	// ignored by the fixed string matchers.
+	Syntax parsers.Syntax
+
+	// Semantics chooses among the matches starting at the leftmost
+	// position. The fixed string matchers are always leftmost-longest.
+	Semantics Semantics
+}
+
+// Semantics selects which match a Regexp reports when several matches
+// start at the same, leftmost, position.
+type Semantics int
+
+const (
+	// LeftmostFirst reports the first match found by the backtracker,
+	// preferring earlier alternatives and greedier repeats, as in Perl.
+	LeftmostFirst Semantics = iota
+
+	// LeftmostLongest reports the longest match, as POSIX requires.
+	// Among equally long matches, capture groups follow the POSIX rule:
+	// from the first group on, each one starts as early and is as long
+	// as possible.
+	LeftmostLongest
+)
+
+// Compile parses a pattern once so it can be reused for every line.
+//
+// Returns:
+//   - *Regexp: the compiled pattern
+//   - error:   if the pattern cannot be parsed
+func Compile(pattern string) (*Regexp, error) {
+	return CompileAll([]string{pattern}, Options{})
+}
+
+// CompileAll compiles several patterns into a single Regexp that matches
+// wherever any of them matches (-e, -f). Every line is scanned once for
+// all patterns together, instead of once per pattern.
+//
+// Returns:
+//   - *Regexp: the compiled patterns
+//   - error:   if any pattern cannot be parsed
+func CompileAll(patterns []string, opts Options) (*Regexp, error) {
+	parsed, err := parsers.NewParser(opts.Syntax).ParseAll(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	re := &Regexp{
+		pattern:      parsed,
+		alternations: make(map[*parsers.Node]*alternationIndex),
+		opts:         opts,
+	}
+	indexAlternations(parsed.Root, re.alternations)
+
+	return re, nil
+}
+
 // MatchPattern tries to match a pattern string against a given line of text.
-// It supports:
-//   - '^' anchor (pattern must match at beginning of line)
-//   - Sequential matching of parsed sub-patterns
 //
 // Params:
 //   - line:   The line (as []byte) to match against
@@ -15,52 +89,179 @@ import (
 //
 // Returns:
 //   - bool:  true if the pattern matches, false otherwise
-//   - error: if parsing or matching fails
+//   - error: if parsing fails
 func MatchPattern(line []byte, pattern string) (bool, error) {
-	// Convert input to runes (to handle Unicode properly)
-	runes := []rune(string(line))
-
-	// Create a parser instance and parse the pattern
-	parser := parsers.NewParser()
-	patterns, err := parser.ParsePatterns(pattern)
+	re, err := Compile(pattern)
 	if err != nil {
 		return false, err
 	}
+	return re.Match(line), nil
+}
 
-	// Extract the first parsed sub-pattern (e.g. "^foo" or "bar")
-	first := patterns.Front().Value.(string)
+// Match reports whether the line contains a match of the pattern. Any
+// match will do, so the search stops at the first one found, whatever
+// the semantics: which match is the longest does not matter here.
+func (re *Regexp) Match(line []byte) bool {
+	in := decode(line)
+	m := newMachine(re, in.runes)
+	m.longest = false
 
-	// Keep track of starting index for matching
-	var startIndex int
-	var status bool
+	for pos := 0; pos <= len(in.runes); pos++ {
+		if _, _, ok := m.matchAt(pos); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAt finds the leftmost match that starts at or after byte offset
+// start. Anchors still see the whole line, so "^a" never matches when
+// start > 0. With -w or -x, candidates that are not whole words or
+// lines are rejected inside the backtracking search, so other
+// alternatives and shorter or later matches are tried in turn.
+//
+// Returns:
+//   - int:  byte offset where the match starts
+//   - int:  byte offset just past the end of the match
+//   - bool: whether a match was found
+func (re *Regexp) FindAt(line []byte, start int) (int, int, bool) {
+	in := decode(line)
+	m := newMachine(re, in.runes)
 
-	// Case 1: Pattern starts with '^' anchor -> must match at start of line
-	if first[0] == '^' {
-		status, startIndex, err = matchIndividualPattern(runes, first[1:], 0, nil)
-		if err != nil || !status {
-			// If the first part fails, no match is possible
-			return false, err
+	for pos := in.runeIndex(start); pos <= len(in.runes); pos++ {
+		if matchStart, end, ok := m.matchAt(pos); ok {
+			return in.offsets[matchStart], in.offsets[end], true
 		}
+	}
 
-		// If the entire string matched and no characters remain, it's a match
-		if startIndex == 1+len(runes) {
-			return true, nil
+	return -1, -1, false
+}
+
+// FindAll returns the byte spans of all successive non-overlapping
+// matches in the line, as [start, end) pairs.
+//
+// An empty match right after the previous match is skipped, and the
+// search always moves forward by at least one character after an empty
+// match, so patterns such as "a*" terminate.
+func (re *Regexp) FindAll(line []byte) [][2]int {
+	in := decode(line)
+	m := newMachine(re, in.runes)
+
+	var spans [][2]int
+	prevEnd := -1
+
+	for pos := 0; pos <= len(in.runes); {
+		matchStart, end, ok := m.matchAt(pos)
+		if !ok {
+			pos++
+			continue
 		}
 
-		// Otherwise, remove the first pattern (already matched) and continue
-		patterns.Remove(patterns.Front())
-	} else {
-		// No '^' anchor, so we try starting from any position
-		startIndex = 0
+		if end == matchStart && end == prevEnd {
+			// Empty match adjacent to the previous one: not a new match
+			pos++
+			continue
+		}
+
+		spans = append(spans, [2]int{in.offsets[matchStart], in.offsets[end]})
+		prevEnd = end
+
+		if end > pos {
+			pos = end
+		} else {
+			pos++
+		}
 	}
 
-	// Case 2: Try matching the remaining patterns starting at every position
-	for pos := startIndex; pos <= len(runes); pos++ {
-		if matchPatternsFromPosition(runes, patterns.Front(), pos) {
-			return true, nil
+	return spans
+}
+
+// FindSubmatch finds the leftmost match and returns its span followed by
+// the span of every capture group, as byte offsets. Groups that did not
+// take part in the match are {-1, -1}. It returns nil when nothing
+// matches.
+func (re *Regexp) FindSubmatch(line []byte) [][2]int {
+	in := decode(line)
+	m := newMachine(re, in.runes)
+
+	for pos := 0; pos <= len(in.runes); pos++ {
+		matchStart, end, ok := m.matchAt(pos)
+		if !ok {
+			continue
+		}
+
+		spans := [][2]int{{in.offsets[matchStart], in.offsets[end]}}
+		for i := 2; i < len(m.caps); i += 2 {
+			if m.caps[i] < 0 || m.caps[i+1] < 0 {
+				spans = append(spans, [2]int{-1, -1})
+				continue
+			}
+			spans = append(spans, [2]int{in.offsets[m.caps[i]], in.offsets[m.caps[i+1]]})
 		}
+		return spans
 	}
 
-	// No match found
-	return false, nil
+	return nil
+}
+
+// input is a line decoded into runes, with the byte offset of every rune.
+// offsets has one extra entry holding the length of the line, so
+// offsets[i] is valid for every match boundary 0 <= i <= len(runes).
+type input struct {
+	runes   []rune
+	offsets []int
+}
+
+// decode splits a line into runes. Invalid UTF-8 bytes become
+// utf8.RuneError but keep their real width, so byte offsets stay exact.
+func decode(line []byte) input {
+	in := input{
+		runes:   make([]rune, 0, len(line)),
+		offsets: make([]int, 0, len(line)+1),
+	}
+
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		in.runes = append(in.runes, r)
+		in.offsets = append(in.offsets, i)
+		i += size
+	}
+	in.offsets = append(in.offsets, len(line))
+
+	return in
+}
+
+// acceptStart reports whether a match may start at rune index pos under
+// the -w and -x restrictions.
+func (o Options) acceptStart(runes []rune, pos int) bool {
+	if o.LineMatch && pos != 0 {
+		return false
+	}
+	if o.WordMatch && pos > 0 && IsAlphanumeric(runes[pos-1]) {
+		return false
+	}
+	return true
+}
+
+// acceptEnd reports whether a match may end at rune index pos under the
+// -w and -x restrictions.
+func (o Options) acceptEnd(runes []rune, pos int) bool {
+	if o.LineMatch && pos != len(runes) {
+		return false
+	}
+	if o.WordMatch && pos < len(runes) && IsAlphanumeric(runes[pos]) {
+		return false
+	}
+	return true
+}
+
+// runeIndex converts a byte offset into the index of the first rune
+// starting at or after it.
+func (in input) runeIndex(offset int) int {
+	for i, o := range in.offsets {
+		if o >= offset {
+			return i
+		}
+	}
+	return len(in.runes)
 }
@@ -0,0 +1,97 @@
+package matcher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindSubmatchSemantics(t *testing.T) {
+	tests := []struct {
+		pattern string
+		line    string
+		first   [][2]int // LeftmostFirst: match span, then group spans
+		longest [][2]int // LeftmostLongest
+	}{
+		{
+			// Both semantics pick the only match of length 4
+			pattern: "(a|ab)(c|bcd)",
+			line:    "abcd",
+			first:   [][2]int{{0, 4}, {0, 1}, {1, 4}},
+			longest: [][2]int{{0, 4}, {0, 1}, {1, 4}},
+		},
+		{
+			pattern: "a|ab",
+			line:    "abc",
+			first:   [][2]int{{0, 1}},
+			longest: [][2]int{{0, 2}},
+		},
+		{
+			// Two matches of length 4: POSIX prefers the longest first group
+			pattern: "(a|ab)(c|bcd)(d*)",
+			line:    "abcd",
+			first:   [][2]int{{0, 4}, {0, 1}, {1, 4}, {4, 4}},
+			longest: [][2]int{{0, 4}, {0, 2}, {2, 3}, {3, 4}},
+		},
+		{
+			pattern: "(a*)*",
+			line:    "aa",
+			first:   [][2]int{{0, 2}, {0, 2}},
+			longest: [][2]int{{0, 2}, {0, 2}},
+		},
+		{
+			pattern: `(a*)b\1`,
+			line:    "aabaa",
+			first:   [][2]int{{0, 5}, {0, 2}},
+			longest: [][2]int{{0, 5}, {0, 2}},
+		},
+		{
+			pattern: "x(y)?",
+			line:    "zx",
+			first:   [][2]int{{1, 2}, {-1, -1}},
+			longest: [][2]int{{1, 2}, {-1, -1}},
+		},
+		{
+			pattern: "b",
+			line:    "aaa",
+			first:   nil,
+			longest: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, semantics := range []Semantics{LeftmostFirst, LeftmostLongest} {
+			want := tt.first
+			if semantics == LeftmostLongest {
+				want = tt.longest
+			}
+
+			re, err := CompileAll([]string{tt.pattern}, Options{Semantics: semantics})
+			if err != nil {
+				t.Fatalf("CompileAll(%q): %v", tt.pattern, err)
+			}
+			if got := re.FindSubmatch([]byte(tt.line)); !reflect.DeepEqual(got, want) {
+				t.Errorf("%q on %q with semantics %d: got %v, want %v", tt.pattern, tt.line, semantics, got, want)
+			}
+		}
+	}
+}
+
+func TestLeftmostLongestAmbiguousRepeat(t *testing.T) {
+	// Every way of splitting the a's between the alternatives is a
+	// match: without memoization this takes exponential time
+	line := []byte(strings.Repeat("a", 1000))
+
+	re, err := CompileAll([]string{"(a|a)*"}, Options{Semantics: LeftmostLongest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !re.Match(line) {
+		t.Fatal("Match: no match")
+	}
+	want := [][2]int{{0, 1000}, {999, 1000}}
+	if got := re.FindSubmatch(line); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSubmatch: got %v, want %v", got, want)
+	}
+}
@@ -1,5 +1,9 @@
 package matcher
 
+import (
+	"grep-go/internal/parsers"
+)
+
 func IsDigit(r rune) bool {
 	return r >= '0' && r <= '9'
 }
@@ -7,3 +11,15 @@ func IsDigit(r rune) bool {
 func IsAlphanumeric(r rune) bool {
 	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
 }
+
+// inClass reports whether r belongs to a character class.
+func inClass(class *parsers.CharClass, r rune) bool {
+	found := false
+	for _, rng := range class.Ranges {
+		if r >= rng.Lo && r <= rng.Hi {
+			found = true
+			break
+		}
+	}
+	return found != class.Negated
+}
@@ -0,0 +1,84 @@
+package parsers
+
+// NodeKind identifies the type of a node in a parsed pattern.
+type NodeKind int
+
+const (
+	NodeEmpty     NodeKind = iota // matches the empty string
+	NodeLiteral                   // Runes: literal text
+	NodeAnyChar                   // . matches any single character
+	NodeClass                     // Class: [abc], [^0-9], \d, \w ...
+	NodeLineStart                 // ^
+	NodeLineEnd                   // $
+	NodeConcat                    // Children matched one after another
+	NodeAlternate                 // Children tried in order: a|b|c
+	NodeGroup                     // Children[0] captured as group Index
+	NodeRepeat                    // Children[0] repeated Min..Max times
+	NodeBackref                   // \1 .. \9: text of group Index
+
+	// Perl syntax only
+	NodeLookahead    // (?=...), (?!...): Children[0] matches (or, if Negated, does not) at this position
+	NodeLookbehind   // (?<=...), (?<!...): Children[0] matches (or not) text ending at this position
+	NodeAtomic       // (?>...), a*+: Children[0] matched once, never backtracked into
+	NodeKeep         // \K: the reported match starts here
+	NodeWordBoundary // \b, or \B when Negated
+)
+
+// Unbounded is the Max of a repetition without an upper limit (*, +, {n,}).
+const Unbounded = -1
+
+// Node is a single element of a parsed pattern. Patterns are parsed
+// into a tree of nodes which the matcher walks with backtracking.
+type Node struct {
+	Kind     NodeKind
+	Runes    []rune     // NodeLiteral: the literal text
+	Class    *CharClass // NodeClass: the set of accepted characters
+	Children []*Node    // sub-patterns of concatenations, alternations, groups and repeats
+	Min      int        // NodeRepeat: minimum number of repetitions
+	Max      int        // NodeRepeat: maximum number of repetitions, or Unbounded
+	Greedy   bool       // NodeRepeat: prefer more repetitions over fewer
+	Index    int        // NodeGroup, NodeBackref: 1-based capture group number
+	Negated  bool       // NodeLookahead, NodeLookbehind, NodeWordBoundary: inverted assertion
+}
+
+// RuneRange is an inclusive range of characters, Lo..Hi.
+type RuneRange struct {
+	Lo, Hi rune
+}
+
+// CharClass is a set of characters given as ranges, optionally negated.
+type CharClass struct {
+	Negated bool
+	Ranges  []RuneRange
+}
+
+// Pattern is the result of parsing a pattern string.
+type Pattern struct {
+	Root   *Node // top of the node tree
+	Groups int   // number of capture groups
+}
+
+// Predefined character sets used by escapes and POSIX bracket expressions.
+var (
+	digitRanges = []RuneRange{{'0', '9'}}
+	wordRanges  = []RuneRange{{'0', '9'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}}
+	spaceRanges = []RuneRange{{'\t', '\r'}, {' ', ' '}}
+
+	horizontalSpaceRanges = []RuneRange{{'\t', '\t'}, {' ', ' '}, {0xa0, 0xa0}, {0x1680, 0x1680}, {0x2000, 0x200a}, {0x202f, 0x202f}, {0x205f, 0x205f}, {0x3000, 0x3000}}
+	verticalSpaceRanges   = []RuneRange{{'\n', '\r'}, {0x85, 0x85}, {0x2028, 0x2029}}
+
+	posixClasses = map[string][]RuneRange{
+		"alnum":  {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}},
+		"alpha":  {{'A', 'Z'}, {'a', 'z'}},
+		"blank":  {{'\t', '\t'}, {' ', ' '}},
+		"cntrl":  {{0, 0x1f}, {0x7f, 0x7f}},
+		"digit":  digitRanges,
+		"graph":  {{'!', '~'}},
+		"lower":  {{'a', 'z'}},
+		"print":  {{' ', '~'}},
+		"punct":  {{'!', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}},
+		"space":  spaceRanges,
+		"upper":  {{'A', 'Z'}},
+		"xdigit": {{'0', '9'}, {'A', 'F'}, {'a', 'f'}},
+	}
+)
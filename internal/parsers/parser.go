@@ -1,234 +1,607 @@
 package parsers
 
 import (
-	"container/list"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
+// Syntax is a regular expression dialect. Every dialect is parsed into
+// the same node tree, so the matcher does not depend on it.
+type Syntax int
+
+const (
+	SyntaxExtended Syntax = iota // POSIX extended regular expressions (-E)
+	SyntaxBasic                  // POSIX basic regular expressions (-G)
+	SyntaxPerl                   // Perl-compatible regular expressions (-P)
+)
+
 // Parser holds a cache of already-parsed patterns
 type Parser struct {
-	cache map[string]*list.List
+	cache  map[string]*Pattern
+	syntax Syntax
 }
 
-// NewParser creates a new Parser instance
-func NewParser() *Parser {
+// NewParser creates a new Parser instance for the given dialect
+func NewParser(syntax Syntax) *Parser {
 	return &Parser{
-		cache: make(map[string]*list.List),
-	}
-}
-
-// ParsePatterns parses a pattern string, using the cache if available
-func (p *Parser) ParsePatterns(pattern string) (*list.List, error) {
-	if lst, exists := p.cache[pattern]; exists {
-		return lst, nil
-	}
-
-	patterns := list.New()
-	runes := []rune(pattern)
-	i := 0
-	for i < len(runes) {
-		if runes[i] == '\\' && i+1 < len(runes) {
-			if i+1 < len(runes) && runes[i+1] == '\\' {
-				// This is \\something - literal backslash followed by something
-				if i+2 < len(runes) {
-					esc := string(runes[i : i+3]) // "\\d" or "\\w"
-					patterns.PushBack(esc)
-					i += 3
-				} else {
-					// Just "\\" at end
-					patterns.PushBack("\\")
-					i += 2
-				}
-			} else {
-				// This is \something - escape sequence
-				esc := string(runes[i : i+2]) // "\d" or "\w"
-				patterns.PushBack(esc)
-				i += 2
-			}
-		} else if runes[i] == '(' {
-			// Handle grouped patterns
-			j := i + 1
-			parenCount := 1
-			for j < len(runes) && parenCount > 0 {
-				switch runes[j] {
-				case '(':
-					parenCount++
-				case ')':
-					parenCount--
-				}
-				j++
-			}
-			if parenCount == 0 {
-				// Found matching closing paren
-				groupContent := string(runes[i+1 : j-1]) // content between ( and )
-
-				// Check if followed by + or ?
-				if j < len(runes) && runes[j] == '+' {
-					// Group with + quantifier - always treat as GRP+ regardless of content
-					patterns.PushBack("GRP+:" + groupContent)
-					i = j + 1
-				} else if j < len(runes) && runes[j] == '?' {
-					// Group with ? quantifier - always treat as GRP? regardless of content
-					patterns.PushBack("GRP?:" + groupContent)
-					i = j + 1
-				} else {
-					// Group without quantifier
-					// Check if this is a simple alternation at the top level
-					// Only treat as ALT: if the entire content is just alternatives separated by |
-					// and no other pattern elements
-					isSimpleAlternation := false
-					if strings.Contains(groupContent, "|") {
-						// Check if it's ONLY alternation (no spaces, quantifiers, etc. outside the alternatives)
-						// For now, let's be more conservative and treat most groups as GRP:
-						// since the group can contain complex sub-patterns
-						parts := strings.Split(groupContent, "|")
-						allSimple := true
-						for _, part := range parts {
-							part = strings.TrimSpace(part)
-							// If any part contains spaces or complex patterns, it's not a simple alternation
-							if strings.Contains(part, " ") || strings.Contains(part, "?") || strings.Contains(part, "+") {
-								allSimple = false
-								break
-							}
-						}
-						isSimpleAlternation = allSimple
-					}
-
-					if isSimpleAlternation {
-						patterns.PushBack("ALT:" + groupContent)
-					} else {
-						patterns.PushBack("GRP:" + groupContent)
-					}
-					i = j
-				}
-			} else {
-				// No closing ), treat ( as literal
-				patterns.PushBack("(")
-				i++
-			}
-		} else if runes[i] == '[' {
-			// Handle character class [abc] or [^abc]
-			j := i + 1
-			for j < len(runes) && runes[j] != ']' {
-				j++
-			}
-			if j < len(runes) {
-				charClass := string(runes[i : j+1]) // include the ]
-				patterns.PushBack(charClass)
-				i = j + 1
-			} else {
-				// No closing ], treat [ as literal
-				patterns.PushBack("[")
-				i++
+		cache:  make(map[string]*Pattern),
+		syntax: syntax,
+	}
+}
+
+// Parse parses a regular expression into a node tree, using the cache
+// if available.
+//
+// Supported extended syntax:
+//   - literals, '.' wildcard, '^' and '$' anchors
+//   - character classes: [abc], [^abc], [a-z], [[:alpha:]]
+//   - escapes: \d \D \w \W \s \S, \1..\9 back references, \<char> literal
+//   - groups and alternation: (abc), a|b, (cat|dog)
+//   - quantifiers: *, +, ?, {n}, {n,}, {n,m}
+//
+// Basic syntax has the same features, but groups, alternation and the
+// quantifiers other than '*' are written \( \) \| \+ \? \{n,m\}, while
+// ( ) | + ? { } are ordinary characters. A '*' starting an expression is
+// literal, '^' is an anchor only at the start of an expression and '$'
+// only at its end.
+//
+// Perl syntax extends the extended syntax with lazy (*?) and possessive
+// (*+) quantifiers, non-capturing (?:...), named (?<name>...) and atomic
+// (?>...) groups, lookaround, \K, \b, comments (?#...) and the usual
+// character escapes; see parsePerlGroup and parsePerlEscape. Constructs
+// the matcher cannot run are rejected with an error naming them.
+//
+// Returns:
+//   - *Pattern: the parsed pattern
+//   - error:    if the pattern is malformed
+func (p *Parser) Parse(pattern string) (*Pattern, error) {
+	if parsed, exists := p.cache[pattern]; exists {
+		return parsed, nil
+	}
+
+	root, groups, err := p.parseOne(pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &Pattern{Root: root, Groups: groups}
+	p.cache[pattern] = parsed
+
+	return parsed, nil
+}
+
+// ParseAll parses several patterns into a single pattern matching any of
+// them (-e, -f). The patterns become the alternatives of one top level
+// alternation, tried in the order given. Capture groups are numbered on
+// from one pattern to the next, and back references of every pattern
+// keep pointing at that pattern's own groups. An empty list gives a
+// pattern that never matches.
+//
+// Returns:
+//   - *Pattern: the combined pattern
+//   - error:    if any pattern is malformed, naming the pattern
+func (p *Parser) ParseAll(patterns []string) (*Pattern, error) {
+	if len(patterns) == 1 {
+		return p.Parse(patterns[0])
+	}
+
+	combined := &Node{Kind: NodeAlternate}
+	groups := 0
+
+	for _, pattern := range patterns {
+		root, total, err := p.parseOne(pattern, groups)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		combined.Children = append(combined.Children, root)
+		groups = total
+	}
+
+	return &Pattern{Root: combined, Groups: groups}, nil
+}
+
+// parseOne parses a single pattern whose capture groups are numbered
+// from base+1.
+//
+// Returns:
+//   - *Node: the root of the pattern
+//   - int:   the number of the last capture group (base if it has none)
+//   - error: if the pattern is malformed
+func (p *Parser) parseOne(pattern string, base int) (*Node, int, error) {
+	state := &parseState{runes: []rune(pattern), syntax: p.syntax, base: base, groups: base}
+	root, err := state.parseAlternation()
+	if err != nil {
+		return nil, 0, err
+	}
+	return root, state.groups, nil
+}
+
+// parseState tracks the position of the parser inside a pattern.
+type parseState struct {
+	runes  []rune
+	pos    int
+	syntax Syntax
+	depth  int // number of currently open groups
+	base   int // number of capture groups of previously parsed patterns
+	groups int // number of the last capture group seen so far
+
+	names map[string]int // Perl syntax: capture group number of every group name
+}
+
+func (s *parseState) more() bool {
+	return s.pos < len(s.runes)
+}
+
+func (s *parseState) peek() rune {
+	return s.runes[s.pos]
+}
+
+// operator returns the length of the text spelling the operator op at
+// the current position, or 0 if op is not there. Extended syntax writes
+// operators as a bare character. Basic syntax escapes every operator
+// but '*' with a backslash, and takes the bare character literally.
+func (s *parseState) operator(op rune) int {
+	if s.syntax != SyntaxBasic || op == '*' {
+		if s.more() && s.peek() == op {
+			return 1
+		}
+		return 0
+	}
+
+	if s.pos+1 < len(s.runes) && s.runes[s.pos] == '\\' && s.runes[s.pos+1] == op {
+		return 2
+	}
+	return 0
+}
+
+// consume skips the operator op if it is at the current position.
+func (s *parseState) consume(op rune) bool {
+	n := s.operator(op)
+	s.pos += n
+	return n > 0
+}
+
+// escaped reports whether the character at i is preceded by an odd
+// number of backslashes, i.e. is itself escaped.
+func (s *parseState) escaped(i int) bool {
+	count := 0
+	for j := i - 1; j >= 0 && s.runes[j] == '\\'; j-- {
+		count++
+	}
+	return count%2 == 1
+}
+
+// expressionStart reports whether i is the start of an expression in
+// basic syntax: the start of the pattern, or right after \( or \|.
+func (s *parseState) expressionStart(i int) bool {
+	if i == 0 {
+		return true
+	}
+	if i < 2 || s.runes[i-2] != '\\' || s.escaped(i-2) {
+		return false
+	}
+	return s.runes[i-1] == '(' || s.runes[i-1] == '|'
+}
+
+// expressionEnd reports whether the current position ends an expression
+// in basic syntax: the end of the pattern, or right before \) or \|.
+func (s *parseState) expressionEnd() bool {
+	return !s.more() || s.operator(')') > 0 || s.operator('|') > 0
+}
+
+// parseAlternation parses "concat | concat | ...".
+func (s *parseState) parseAlternation() (*Node, error) {
+	var alternatives []*Node
+
+	for {
+		concat, err := s.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, concat)
+
+		if s.consume('|') {
+			continue
+		}
+		break
+	}
+
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return &Node{Kind: NodeAlternate, Children: alternatives}, nil
+}
+
+// parseConcat parses a sequence of quantified atoms up to '|', a closing
+// ')' of an open group, or the end of the pattern.
+func (s *parseState) parseConcat() (*Node, error) {
+	concat := &Node{Kind: NodeConcat}
+
+	for s.more() {
+		// Outside of any group an unmatched ')' is a literal character
+		if s.operator('|') > 0 || (s.operator(')') > 0 && s.depth > 0) {
+			break
+		}
+
+		if s.syntax == SyntaxPerl {
+			skipped, err := s.skipComment()
+			if err != nil {
+				return nil, err
 			}
-		} else if runes[i] == '.' && i+1 < len(runes) && runes[i+1] == '+' {
-			// Handle .+ pattern specifically
-			patterns.PushBack(".+")
-			i += 2
-		} else if runes[i] == '+' {
-			// handle one or many +
-			var last_val = patterns.Back()
-			patterns.Remove(patterns.Back())
-
-			if last_val == nil {
-				return nil, fmt.Errorf("error in parsing")
+			if skipped {
+				continue
 			}
+		}
+
+		// A quantifier at the start of an expression (or right after
+		// a '^' anchor) has nothing to repeat
+		n := len(concat.Children)
+		first := n == 0 || concat.Children[n-1].Kind == NodeLineStart
+
+		atom, err := s.parseAtom(first)
+		if err != nil {
+			return nil, err
+		}
+
+		atom, err = s.parseQuantifiers(atom)
+		if err != nil {
+			return nil, err
+		}
+
+		appendNode(concat, atom)
+	}
+
+	switch len(concat.Children) {
+	case 0:
+		return &Node{Kind: NodeEmpty}, nil
+	case 1:
+		return concat.Children[0], nil
+	}
+	return concat, nil
+}
+
+// appendNode adds a node to a concatenation, merging consecutive literals
+// into a single literal node.
+func appendNode(concat *Node, node *Node) {
+	if n := len(concat.Children); n > 0 && node.Kind == NodeLiteral {
+		last := concat.Children[n-1]
+		if last.Kind == NodeLiteral {
+			last.Runes = append(last.Runes, node.Runes...)
+			return
+		}
+	}
+	concat.Children = append(concat.Children, node)
+}
+
+// parseAtom parses a single element that a quantifier can apply to.
+// first reports whether the atom starts its concatenation, where a
+// quantifier character has nothing to repeat and is taken literally.
+func (s *parseState) parseAtom(first bool) (*Node, error) {
+	start := s.pos
+	if s.consume('(') {
+		if s.syntax == SyntaxPerl && s.more() && (s.peek() == '?' || s.peek() == '*') {
+			return s.parsePerlGroup(start)
+		}
+		return s.parseGroup(start)
+	}
 
-			last_pat := last_val.Value.(string)
-			// fmt.println("-->", last_pat)
+	r := s.peek()
+	s.pos++
 
-			var last_char string = string(last_pat[len(last_pat)-1])
-			var rest_patt string = last_pat[0 : len(last_pat)-1]
+	switch r {
+	case '[':
+		class, err := s.parseClass(start)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: NodeClass, Class: class}, nil
+
+	case '.':
+		return &Node{Kind: NodeAnyChar}, nil
+
+	case '^':
+		if s.syntax != SyntaxBasic || s.expressionStart(start) {
+			return &Node{Kind: NodeLineStart}, nil
+		}
+
+	case '$':
+		if s.syntax != SyntaxBasic || s.expressionEnd() {
+			return &Node{Kind: NodeLineEnd}, nil
+		}
+
+	case '\\':
+		return s.parseEscape(start)
+
+	case ')':
+		if s.syntax == SyntaxPerl {
+			return nil, fmt.Errorf("unmatched ) at position %d", start)
+		}
+
+	case '*', '+', '?':
+		// Perl syntax never takes a quantifier character literally
+		if (!first && s.syntax != SyntaxBasic) || s.syntax == SyntaxPerl {
+			return nil, fmt.Errorf("nothing to repeat at position %d", start)
+		}
+	}
+
+	return literal(r), nil
+}
+
+// parseGroup parses a capture group whose opening parenthesis starting
+// at start has already been consumed.
+func (s *parseState) parseGroup(start int) (*Node, error) {
+	s.groups++
+	index := s.groups
+	inner, err := s.parseGroupBody(start)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Kind: NodeGroup, Index: index, Children: []*Node{inner}}, nil
+}
+
+// parseGroupBody parses the content of a group opened at start, up to
+// and including its closing parenthesis.
+func (s *parseState) parseGroupBody(start int) (*Node, error) {
+	s.depth++
+	inner, err := s.parseAlternation()
+	s.depth--
+	if err != nil {
+		return nil, err
+	}
+	if !s.consume(')') {
+		return nil, fmt.Errorf("unmatched %s at position %d", s.spell('('), start)
+	}
+	return inner, nil
+}
+
+// spell returns how the operator op is written in the current syntax,
+// for error messages.
+func (s *parseState) spell(op rune) string {
+	if s.syntax == SyntaxBasic && op != '*' {
+		return "\\" + string(op)
+	}
+	return string(op)
+}
+
+// parseEscape parses the character following a backslash.
+func (s *parseState) parseEscape(start int) (*Node, error) {
+	if !s.more() {
+		return nil, fmt.Errorf("trailing backslash at position %d", start)
+	}
+
+	r := s.peek()
+	s.pos++
+
+	if s.syntax == SyntaxPerl {
+		return s.parsePerlEscape(r, start)
+	}
+
+	switch r {
+	case 'd', 'D', 'w', 'W', 's', 'S':
+		return &Node{Kind: NodeClass, Class: escapeClass(r)}, nil
+	}
+
+	if s.syntax == SyntaxBasic {
+		switch r {
+		case ')':
+			return nil, fmt.Errorf("unmatched \\) at position %d", start)
+		case '+', '?', '{':
+			// A quantifier starting an expression has nothing to
+			// repeat and is taken literally, like a leading '*'
+			return literal(r), nil
+		}
+	}
+
+	if r >= '1' && r <= '9' {
+		return s.backref(s.base+int(r-'0'), start)
+	}
+
+	// Any other escaped character stands for itself: \. \( \\ ...
+	return literal(r), nil
+}
+
+// backref returns a back reference to capture group index, which must
+// already have been opened, for the escape starting at start.
+func (s *parseState) backref(index int, start int) (*Node, error) {
+	if index <= s.base || index > s.groups {
+		return nil, fmt.Errorf("invalid back reference %s at position %d", string(s.runes[start:s.pos]), start)
+	}
+	return &Node{Kind: NodeBackref, Index: index}, nil
+}
+
+// escapeClass returns the character class of a \d, \w or \s escape, or
+// of the Perl \h and \v escapes. Upper case letters negate the class.
+func escapeClass(r rune) *CharClass {
+	var ranges []RuneRange
+	switch r {
+	case 'd', 'D':
+		ranges = digitRanges
+	case 'w', 'W':
+		ranges = wordRanges
+	case 's', 'S':
+		ranges = spaceRanges
+	case 'h', 'H':
+		ranges = horizontalSpaceRanges
+	case 'v', 'V':
+		ranges = verticalSpaceRanges
+	}
+	return &CharClass{Negated: r >= 'A' && r <= 'Z', Ranges: ranges}
+}
+
+// parseClass parses a bracket expression; the opening '[' has already
+// been consumed.
+func (s *parseState) parseClass(start int) (*CharClass, error) {
+	class := &CharClass{}
+
+	if s.more() && s.peek() == '^' {
+		class.Negated = true
+		s.pos++
+	}
+
+	for first := true; ; first = false {
+		if !s.more() {
+			return nil, fmt.Errorf("unmatched [ at position %d", start)
+		}
 
-			if len(rest_patt) > 0 {
-				patterns.PushBack(rest_patt)
+		r := s.peek()
+		if r == ']' && !first {
+			s.pos++
+			return class, nil
+		}
+
+		// POSIX named class: [:alpha:]
+		if r == '[' && s.pos+1 < len(s.runes) && s.runes[s.pos+1] == ':' {
+			rest := string(s.runes[s.pos+2:])
+			end := strings.Index(rest, ":]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class name at position %d", s.pos)
+			}
+			name := rest[:end]
+			ranges, ok := posixClasses[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid character class [:%s:] at position %d", name, s.pos)
 			}
+			class.Ranges = append(class.Ranges, ranges...)
+			s.pos += 2 + len([]rune(name)) + 2
+			continue
+		}
 
-			patterns.PushBack("+" + last_char)
-			i++
-		} else if runes[i] == '?' {
-			// handle one or none ?
-			var last_val = patterns.Back()
-			patterns.Remove(patterns.Back())
+		s.pos++
+		lo := r
 
-			if last_val == nil {
-				return nil, fmt.Errorf("error in parsing")
+		// Perl syntax allows escapes inside classes: [\d\]], [\x00-\x1f]
+		if r == '\\' && s.syntax == SyntaxPerl {
+			var ranges []RuneRange
+			var err error
+			lo, ranges, err = s.parseClassEscape(s.pos - 1)
+			if err != nil {
+				return nil, err
+			}
+			if ranges != nil {
+				class.Ranges = append(class.Ranges, ranges...)
+				continue
 			}
+		}
 
-			last_pat := last_val.Value.(string)
-			var last_char string = string(last_pat[len(last_pat)-1])
-			var rest_patt string = last_pat[0 : len(last_pat)-1]
+		// Range a-z, unless '-' is the last character of the class
+		if s.pos+1 < len(s.runes) && s.peek() == '-' && s.runes[s.pos+1] != ']' {
+			rangeStart := s.pos - 1
+			s.pos++
+			hi := s.peek()
+			s.pos++
 
-			if len(rest_patt) > 0 {
-				patterns.PushBack(rest_patt)
+			if hi == '\\' && s.syntax == SyntaxPerl {
+				var ranges []RuneRange
+				var err error
+				hi, ranges, err = s.parseClassEscape(s.pos - 1)
+				if err != nil {
+					return nil, err
+				}
+				if ranges != nil {
+					return nil, fmt.Errorf("invalid range end %s at position %d", string(s.runes[rangeStart:s.pos]), rangeStart)
+				}
 			}
 
-			patterns.PushBack("?" + last_char)
-			i++
-		} else if runes[i] == '.' {
-			patterns.PushBack(".")
-			i += 1
-		} else {
-			// Collect normal characters until next special character
-			j := i
-			for j < len(runes) && runes[j] != '\\' && runes[j] != '[' && runes[j] != '+' && runes[j] != '?' && runes[j] != '.' && runes[j] != '(' {
-				j++
+			if hi < lo {
+				return nil, fmt.Errorf("invalid range %c-%c at position %d", lo, hi, rangeStart)
 			}
+			class.Ranges = append(class.Ranges, RuneRange{lo, hi})
+			continue
+		}
 
-			if j-1 >= 0 && j < len(runes) && runes[j] == '+' {
-				// "ca+ts" should accept cats and caats
-				substr := string(runes[i : j-1])
-				if len(substr) > 0 {
-					patterns.PushBack(substr)
-				}
-				pos_str := "+" + string(runes[j-1:j])
-				patterns.PushBack(pos_str)
-				i = j + 1
-				continue
+		class.Ranges = append(class.Ranges, RuneRange{lo, lo})
+	}
+}
 
-			} else if j-1 >= 0 && j < len(runes) && runes[j] == '?' {
-				// "ca?ts"
-				substr := string(runes[i : j-1])
-				if len(substr) > 0 {
-					patterns.PushBack(substr)
-				}
-				pos_str := "?" + string(runes[j-1:j])
-				patterns.PushBack(pos_str)
-				i = j + 1
-				continue
+// parseQuantifiers applies any quantifiers following an atom.
+func (s *parseState) parseQuantifiers(atom *Node) (*Node, error) {
+	if s.syntax == SyntaxBasic && atom.Kind == NodeLineStart {
+		// "^*" matches a literal '*' at the start of the line
+		return atom, nil
+	}
+
+	for s.more() {
+		min, max := 0, Unbounded
 
-			} else if j-1 >= 0 && j < len(runes) && runes[j] == '.' {
-				// Check if it's followed by +
-				if j+1 < len(runes) && runes[j+1] == '+' {
-					// "ca.+ts" pattern
-					substr := string(runes[i:j])
-					if len(substr) > 0 {
-						patterns.PushBack(substr)
-					}
-					patterns.PushBack(".+")
-					i = j + 2
-					continue
-				} else {
-					// "ca.ts"
-					substr := string(runes[i:j])
-					if len(substr) > 0 {
-						patterns.PushBack(substr)
-					}
-					patterns.PushBack(".")
-					i = j + 1
-					continue
+		switch {
+		case s.consume('*'):
+		case s.consume('+'):
+			min = 1
+		case s.consume('?'):
+			max = 1
+		case s.operator('{') > 0:
+			var ok bool
+			min, max, ok = s.parseInterval()
+			if !ok {
+				if s.syntax == SyntaxBasic {
+					return nil, fmt.Errorf("invalid interval \\{ at position %d", s.pos)
 				}
+				// Not a valid interval: '{' is a literal character
+				return atom, nil
 			}
-			if j > i {
-				substr := string(runes[i:j])
-				patterns.PushBack(substr)
-			}
-			i = j
+		default:
+			return atom, nil
+		}
+
+		if max != Unbounded && min > max {
+			return nil, fmt.Errorf("invalid repetition count {%d,%d}", min, max)
+		}
+
+		atom = &Node{Kind: NodeRepeat, Min: min, Max: max, Greedy: true, Children: []*Node{atom}}
+
+		if s.syntax == SyntaxPerl {
+			// A second quantifier makes the first one lazy (a*?) or
+			// possessive (a*+); any other one is an error, reported
+			// by parseAtom
+			return s.parseQuantifierSuffix(atom), nil
 		}
 	}
 
-	p.cache[pattern] = patterns
+	return atom, nil
+}
+
+// parseInterval parses {n}, {n,}, {,m} or {n,m} (\{n,m\} in basic
+// syntax) at the current position. As in GNU grep, an empty lower bound
+// is 0, so {,m} is {0,m}. It leaves the position untouched and returns
+// ok == false when the text is not a well formed interval.
+func (s *parseState) parseInterval() (min int, max int, ok bool) {
+	open := s.operator('{')
+	closing := s.spell('}')
+
+	rest := string(s.runes[s.pos+open:])
+	end := strings.Index(rest, closing)
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	body := rest[:end]
+	lo, hi, hasComma := strings.Cut(body, ",")
+
+	var err error
+	if lo != "" || !hasComma {
+		min, err = strconv.Atoi(lo)
+		if err != nil || min < 0 {
+			return 0, 0, false
+		}
+	}
+
+	switch {
+	case !hasComma:
+		max = min
+	case hi == "":
+		max = Unbounded
+	default:
+		max, err = strconv.Atoi(hi)
+		if err != nil || max < 0 {
+			return 0, 0, false
+		}
+	}
+
+	s.pos += open + len([]rune(rest[:end+len(closing)]))
+	return min, max, true
+}
 
-	return patterns, nil
+func literal(r rune) *Node {
+	return &Node{Kind: NodeLiteral, Runes: []rune{r}}
 }
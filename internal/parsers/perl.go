@@ -0,0 +1,536 @@
+package parsers
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxGroupName is the length limit of a group name, as in PCRE.
+const maxGroupName = 32
+
+// hasPrefix reports whether the pattern continues with prefix at the
+// current position.
+func (s *parseState) hasPrefix(prefix string) bool {
+	i := s.pos
+	for _, r := range prefix {
+		if i >= len(s.runes) || s.runes[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// skipComment skips a (?#...) comment at the current position.
+func (s *parseState) skipComment() (bool, error) {
+	if !s.hasPrefix("(?#") {
+		return false, nil
+	}
+
+	start := s.pos
+	for s.pos += 3; s.more(); s.pos++ {
+		if s.peek() == ')' {
+			s.pos++
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("unterminated comment (?# at position %d", start)
+}
+
+// unsupported reports a Perl construct that the matcher cannot run.
+func unsupported(construct string, start int) error {
+	return fmt.Errorf("%s is not supported at position %d", construct, start)
+}
+
+// parsePerlGroup parses the groups of Perl syntax introduced by "(?" or
+// "(*". The opening parenthesis at start has already been consumed.
+//
+// Supported:
+//   - (?:...)                                   non-capturing group
+//   - (?<name>...), (?'name'...), (?P<name>...) named capture group
+//   - (?P=name)                                 back reference to a named group
+//   - (?>...)                                   atomic group
+//   - (?=...), (?!...)                          lookahead
+//   - (?<=...), (?<!...)                        lookbehind, of any length
+//
+// Comments (?#...) are skipped by parseConcat. Inline options,
+// recursion, conditionals, branch resets, callouts and backtracking
+// verbs are rejected.
+func (s *parseState) parsePerlGroup(start int) (*Node, error) {
+	if s.peek() == '*' {
+		if s.pos+1 < len(s.runes) && (unicode.IsUpper(s.runes[s.pos+1]) || s.runes[s.pos+1] == ':') {
+			return nil, unsupported("backtracking control verb (*VERB)", start)
+		}
+		// A plain '(' followed by '*', which parseAtom rejects
+		return s.parseGroup(start)
+	}
+	s.pos++ // '?'
+
+	switch {
+	case s.hasPrefix(":"):
+		s.pos++
+		return s.parseGroupBody(start)
+
+	case s.hasPrefix(">"):
+		s.pos++
+		return s.parseSpecialGroup(NodeAtomic, false, start)
+
+	case s.hasPrefix("="), s.hasPrefix("!"):
+		negated := s.peek() == '!'
+		s.pos++
+		return s.parseSpecialGroup(NodeLookahead, negated, start)
+
+	case s.hasPrefix("<="), s.hasPrefix("<!"):
+		negated := s.runes[s.pos+1] == '!'
+		s.pos += 2
+		return s.parseSpecialGroup(NodeLookbehind, negated, start)
+
+	case s.hasPrefix("<"), s.hasPrefix("'"), s.hasPrefix("P<"):
+		return s.parseNamedGroup(start)
+
+	case s.hasPrefix("P="):
+		s.pos += 2
+		name, err := s.parseGroupName(')', start)
+		if err != nil {
+			return nil, err
+		}
+		return s.namedBackref(name, start)
+
+	case s.hasPrefix("P>"), s.hasPrefix("&"), s.hasPrefix("R"),
+		s.more() && (s.peek() == '+' || s.peek() == '-' && s.pos+1 < len(s.runes) && isDigit(s.runes[s.pos+1]) || isDigit(s.peek())):
+		return nil, unsupported("recursion or subroutine call "+s.excerpt(start, ')'), start)
+
+	case s.hasPrefix("("):
+		return nil, unsupported("conditional group (?(...)", start)
+
+	case s.hasPrefix("|"):
+		return nil, unsupported("branch reset group (?|...)", start)
+
+	case s.hasPrefix("C"):
+		return nil, unsupported("callout "+s.excerpt(start, ')'), start)
+
+	case s.more() && strings.ContainsRune("imnsxJU^-", s.peek()):
+		return nil, unsupported("inline option "+s.excerpt(start, ')', ':'), start)
+	}
+
+	return nil, fmt.Errorf("unknown group syntax %s at position %d", s.excerpt(start, ')'), start)
+}
+
+// excerpt returns the pattern text from start up to the first of the
+// stop characters (included), for error messages.
+func (s *parseState) excerpt(start int, stop ...rune) string {
+	end := start
+	for end < len(s.runes) {
+		end++
+		if slices.Contains(stop, s.runes[end-1]) {
+			break
+		}
+	}
+	return string(s.runes[start:end])
+}
+
+// parseSpecialGroup parses the content of a lookaround or atomic group
+// into a node of the given kind.
+func (s *parseState) parseSpecialGroup(kind NodeKind, negated bool, start int) (*Node, error) {
+	inner, err := s.parseGroupBody(start)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Kind: kind, Negated: negated, Children: []*Node{inner}}, nil
+}
+
+// parseNamedGroup parses (?<name>...), (?'name'...) or (?P<name>...),
+// positioned right after "(?". Named groups are numbered along with the
+// unnamed ones, so \1 works on them too.
+func (s *parseState) parseNamedGroup(start int) (*Node, error) {
+	closing := '>'
+	switch {
+	case s.hasPrefix("P<"):
+		s.pos += 2
+	case s.hasPrefix("'"):
+		closing = '\''
+		s.pos++
+	default:
+		s.pos++
+	}
+
+	name, err := s.parseGroupName(closing, start)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := s.names[name]; exists {
+		return nil, fmt.Errorf("duplicate group name %q at position %d", name, start)
+	}
+
+	s.groups++
+	index := s.groups
+	if s.names == nil {
+		s.names = make(map[string]int)
+	}
+	s.names[name] = index
+
+	inner, err := s.parseGroupBody(start)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Kind: NodeGroup, Index: index, Children: []*Node{inner}}, nil
+}
+
+// parseGroupName reads a group name up to the closing character, which
+// is consumed too.
+func (s *parseState) parseGroupName(closing rune, start int) (string, error) {
+	begin := s.pos
+	for s.more() && s.peek() != closing {
+		s.pos++
+	}
+	if !s.more() {
+		return "", fmt.Errorf("unterminated group name at position %d", start)
+	}
+
+	name := string(s.runes[begin:s.pos])
+	s.pos++
+
+	if !validGroupName(name) {
+		return "", fmt.Errorf("invalid group name %q at position %d", name, start)
+	}
+	return name, nil
+}
+
+// validGroupName reports whether name is made of word characters, does
+// not start with a digit and is at most maxGroupName characters long.
+func validGroupName(name string) bool {
+	if name == "" || utf8.RuneCountInString(name) > maxGroupName {
+		return false
+	}
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// namedBackref returns a back reference to the group called name.
+func (s *parseState) namedBackref(name string, start int) (*Node, error) {
+	index, ok := s.names[name]
+	if !ok {
+		return nil, fmt.Errorf("reference to unknown group name %q at position %d", name, start)
+	}
+	return &Node{Kind: NodeBackref, Index: index}, nil
+}
+
+// parsePerlEscape parses the character r following a backslash at start
+// in Perl syntax. On top of the escapes of extended syntax it supports:
+//   - \h \H \v \V horizontal and vertical space, \N any character
+//   - \b \B word boundaries, \A and \z \Z line start and end, \K
+//   - \k<name> \k'name' \k{name} \g{name} named back references,
+//     \gN \g{N} \g{-N} numbered and relative back references
+//   - \t \n \r \f \e \a \0nn \o{nnn} \xhh \x{hhhh} \cX characters
+//   - \Q...\E quoted text
+//
+// Any other letter or digit is an error, any other character is literal.
+func (s *parseState) parsePerlEscape(r rune, start int) (*Node, error) {
+	switch r {
+	case 'd', 'D', 'w', 'W', 's', 'S', 'h', 'H', 'v', 'V':
+		return &Node{Kind: NodeClass, Class: escapeClass(r)}, nil
+
+	case 'N':
+		if s.hasPrefix("{") {
+			return nil, unsupported("named character \\N{...}", start)
+		}
+		return &Node{Kind: NodeAnyChar}, nil
+
+	case 'b', 'B':
+		return &Node{Kind: NodeWordBoundary, Negated: r == 'B'}, nil
+
+	case 'A':
+		return &Node{Kind: NodeLineStart}, nil
+
+	case 'z', 'Z':
+		return &Node{Kind: NodeLineEnd}, nil
+
+	case 'K':
+		return &Node{Kind: NodeKeep}, nil
+
+	case 'Q':
+		return s.parseQuote()
+
+	case 'E':
+		// \E without a \Q is ignored
+		return &Node{Kind: NodeEmpty}, nil
+
+	case 'k':
+		closings := map[rune]rune{'<': '>', '\'': '\'', '{': '}'}
+		if !s.more() || closings[s.peek()] == 0 {
+			return nil, fmt.Errorf("\\k must be followed by a name in <>, '' or {} at position %d", start)
+		}
+		closing := closings[s.peek()]
+		s.pos++
+		name, err := s.parseGroupName(closing, start)
+		if err != nil {
+			return nil, err
+		}
+		return s.namedBackref(name, start)
+
+	case 'g':
+		return s.parseGReference(start)
+
+	case 'G', 'X', 'R', 'C', 'p', 'P', 'L', 'l', 'U', 'u', 'F':
+		return nil, unsupported("escape "+string(s.runes[start:s.pos]), start)
+	}
+
+	if r >= '1' && r <= '9' {
+		return s.backref(s.base+int(r-'0'), start)
+	}
+
+	c, ok, err := s.parseCharEscape(r, start)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return literal(c), nil
+	}
+
+	if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return nil, fmt.Errorf("unrecognized escape \\%c at position %d", r, start)
+	}
+
+	// Any other escaped character stands for itself: \. \( \\ ...
+	return literal(r), nil
+}
+
+// parseGReference parses the reference of a \g escape: \gN, \g{N},
+// \g-N and \g{-N} refer to groups by number, relative ones counting
+// back from the last group opened, and \g{name} by name.
+func (s *parseState) parseGReference(start int) (*Node, error) {
+	braced := s.hasPrefix("{")
+	if braced {
+		s.pos++
+	}
+
+	begin := s.pos
+	if braced {
+		for s.more() && s.peek() != '}' {
+			s.pos++
+		}
+		if !s.more() {
+			return nil, fmt.Errorf("unterminated \\g{ at position %d", start)
+		}
+	} else {
+		if s.hasPrefix("-") {
+			s.pos++
+		}
+		for s.more() && isDigit(s.peek()) {
+			s.pos++
+		}
+	}
+	ref := string(s.runes[begin:s.pos])
+	if braced {
+		s.pos++
+	}
+
+	n, err := strconv.Atoi(ref)
+	if err != nil {
+		if braced && validGroupName(ref) {
+			return s.namedBackref(ref, start)
+		}
+		return nil, fmt.Errorf("\\g must be followed by a number or a name in {} at position %d", start)
+	}
+
+	if strings.HasPrefix(ref, "-") {
+		return s.backref(s.groups+n+1, start)
+	}
+	return s.backref(s.base+n, start)
+}
+
+// parseQuote handles \Q...\E, positioned right after the \Q. The quoted
+// characters are rewritten in place as escaped literals, so a following
+// quantifier applies to the last of them only, as in Perl. A missing \E
+// quotes the rest of the pattern.
+func (s *parseState) parseQuote() (*Node, error) {
+	end := s.pos
+	for end < len(s.runes) && !(s.runes[end] == '\\' && end+1 < len(s.runes) && s.runes[end+1] == 'E') {
+		end++
+	}
+
+	var quoted []rune
+	for _, r := range s.runes[s.pos:end] {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			quoted = append(quoted, r)
+		} else {
+			quoted = append(quoted, '\\', r)
+		}
+	}
+
+	rest := s.runes[min(end+2, len(s.runes)):]
+	s.runes = slices.Concat(s.runes[:s.pos], quoted, rest)
+
+	if len(quoted) == 0 {
+		return &Node{Kind: NodeEmpty}, nil
+	}
+	return s.parseAtom(false)
+}
+
+// parseCharEscape parses the escapes standing for a single character,
+// r being the character after the backslash at start.
+//
+// Returns:
+//   - rune:  the character
+//   - bool:  whether r starts such an escape
+//   - error: if the escape is malformed
+func (s *parseState) parseCharEscape(r rune, start int) (rune, bool, error) {
+	switch r {
+	case 't':
+		return '\t', true, nil
+	case 'n':
+		return '\n', true, nil
+	case 'r':
+		return '\r', true, nil
+	case 'f':
+		return '\f', true, nil
+	case 'e':
+		return 0x1b, true, nil
+	case 'a':
+		return 0x07, true, nil
+
+	case '0':
+		// Up to two more octal digits
+		begin := s.pos
+		for s.pos < begin+2 && s.more() && s.peek() >= '0' && s.peek() <= '7' {
+			s.pos++
+		}
+		return s.codePoint("0"+string(s.runes[begin:s.pos]), 8, start)
+
+	case 'o':
+		if !s.hasPrefix("{") {
+			return 0, false, fmt.Errorf("\\o must be followed by {} at position %d", start)
+		}
+		digits, err := s.braced(start)
+		if err != nil {
+			return 0, false, err
+		}
+		return s.codePoint(digits, 8, start)
+
+	case 'x':
+		if s.hasPrefix("{") {
+			digits, err := s.braced(start)
+			if err != nil {
+				return 0, false, err
+			}
+			return s.codePoint(digits, 16, start)
+		}
+		// Up to two hex digits; \x alone is NUL
+		begin := s.pos
+		for s.pos < begin+2 && s.more() && strings.ContainsRune("0123456789abcdefABCDEF", s.peek()) {
+			s.pos++
+		}
+		return s.codePoint("0"+string(s.runes[begin:s.pos]), 16, start)
+
+	case 'c':
+		if !s.more() || s.peek() >= utf8.RuneSelf {
+			return 0, false, fmt.Errorf("\\c must be followed by an ASCII character at position %d", start)
+		}
+		c := unicode.ToUpper(s.peek()) ^ 0x40
+		s.pos++
+		return c, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// braced reads the text of a {...} argument at the current position.
+func (s *parseState) braced(start int) (string, error) {
+	end := s.pos
+	for end < len(s.runes) && s.runes[end] != '}' {
+		end++
+	}
+	if end == len(s.runes) {
+		return "", fmt.Errorf("missing } in escape at position %d", start)
+	}
+
+	text := string(s.runes[s.pos+1 : end])
+	s.pos = end + 1
+	return text, nil
+}
+
+// codePoint converts the digits of a character escape into a rune.
+func (s *parseState) codePoint(digits string, base int, start int) (rune, bool, error) {
+	n, err := strconv.ParseInt(digits, base, 32)
+	if err != nil || n > unicode.MaxRune {
+		return 0, false, fmt.Errorf("invalid character code %s at position %d", string(s.runes[start:s.pos]), start)
+	}
+	return rune(n), true, nil
+}
+
+// parseClassEscape parses an escape inside a bracket expression in Perl
+// syntax, positioned right after the backslash at start. Class escapes
+// such as \d or \W give a set of ranges, every other escape a single
+// character; \b is a backspace there.
+func (s *parseState) parseClassEscape(start int) (rune, []RuneRange, error) {
+	if !s.more() {
+		return 0, nil, fmt.Errorf("trailing backslash at position %d", start)
+	}
+
+	r := s.peek()
+	s.pos++
+
+	switch r {
+	case 'd', 'w', 's', 'h', 'v':
+		return 0, escapeClass(r).Ranges, nil
+	case 'D', 'W', 'S', 'H', 'V':
+		return 0, complementRanges(escapeClass(r).Ranges), nil
+	case 'b':
+		return '\b', nil, nil
+	}
+
+	c, ok, err := s.parseCharEscape(r, start)
+	if err != nil {
+		return 0, nil, err
+	}
+	if ok {
+		return c, nil, nil
+	}
+
+	if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return 0, nil, fmt.Errorf("unrecognized escape \\%c in character class at position %d", r, start)
+	}
+	return r, nil, nil
+}
+
+// complementRanges returns the characters outside of sorted,
+// non-overlapping ranges.
+func complementRanges(ranges []RuneRange) []RuneRange {
+	var complement []RuneRange
+	next := rune(0)
+	for _, rng := range ranges {
+		if rng.Lo > next {
+			complement = append(complement, RuneRange{next, rng.Lo - 1})
+		}
+		next = rng.Hi + 1
+	}
+	if next <= unicode.MaxRune {
+		complement = append(complement, RuneRange{next, unicode.MaxRune})
+	}
+	return complement
+}
+
+// parseQuantifierSuffix applies the Perl suffix of a quantifier: '?'
+// makes the repeat lazy, '+' makes it possessive, i.e. an atomic group
+// around the repeat.
+func (s *parseState) parseQuantifierSuffix(repeat *Node) *Node {
+	switch {
+	case s.consume('?'):
+		repeat.Greedy = false
+	case s.consume('+'):
+		return &Node{Kind: NodeAtomic, Children: []*Node{repeat}}
+	}
+	return repeat
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
@@ -0,0 +1,82 @@
+package printer
+
+import (
+	"sync"
+)
+
+// Buffer is an Output that records everything reported to it, so it can
+// be streamed onto another Output later. Files searched concurrently
+// are each recorded into their own Buffer, then streamed one after the
+// other, so the output of a file is never interleaved with another one.
+//
+// Once Stream has been called, the Buffer stops recording and forwards
+// everything straight to the target Output, so the file whose turn it
+// is to be printed does not have to be held in memory. A Buffer may be
+// written to by one goroutine while another one calls Stream.
+type Buffer struct {
+	mu     sync.Mutex
+	events []func(out Output)
+	target Output // set by Stream
+}
+
+// NewBuffer creates an empty Buffer.
+func NewBuffer() *Buffer {
+	return &Buffer{}
+}
+
+func (b *Buffer) BeginFile(file string) {
+	b.record(func(out Output) { out.BeginFile(file) })
+}
+
+func (b *Buffer) PrintLine(file string, line Line) {
+	b.record(func(out Output) { out.PrintLine(file, line) })
+}
+
+func (b *Buffer) PrintContext(file string, line Line) {
+	b.record(func(out Output) { out.PrintContext(file, line) })
+}
+
+func (b *Buffer) PrintCount(file string, count int) {
+	b.record(func(out Output) { out.PrintCount(file, count) })
+}
+
+func (b *Buffer) PrintFileName(file string) {
+	b.record(func(out Output) { out.PrintFileName(file) })
+}
+
+func (b *Buffer) PrintBinaryMatch(file string, offset int64) {
+	b.record(func(out Output) { out.PrintBinaryMatch(file, offset) })
+}
+
+func (b *Buffer) EndFile(file string, stats Stats) {
+	b.record(func(out Output) { out.EndFile(file, stats) })
+}
+
+// Finish does nothing: a Buffer is finished by streaming it.
+func (b *Buffer) Finish() error {
+	return nil
+}
+
+// Stream replays what has been recorded so far onto out, then forwards
+// everything reported afterwards directly to it.
+func (b *Buffer) Stream(out Output) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range b.events {
+		event(out)
+	}
+	b.events = nil
+	b.target = out
+}
+
+func (b *Buffer) record(event func(out Output)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.target != nil {
+		event(b.target)
+		return
+	}
+	b.events = append(b.events, event)
+}
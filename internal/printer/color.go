@@ -0,0 +1,119 @@
+package printer
+
+import (
+	"os"
+	"strings"
+)
+
+// Colors holds the SGR sequences (such as "01;31") used to highlight
+// each part of the output. An empty sequence leaves that part uncolored.
+// The field names follow the capabilities of GNU grep's GREP_COLORS.
+type Colors struct {
+	SelectedMatch string // ms: matched text in selected lines
+	ContextMatch  string // mc: matched text in context lines
+	SelectedLine  string // sl: whole selected lines
+	ContextLine   string // cx: whole context lines
+	FileName      string // fn: file names
+	LineNumber    string // ln: line numbers
+	ByteOffset    string // bn: byte offsets
+	Separator     string // se: ':', '-' and "--" separators
+
+	// NoErase disables the "erase to end of line" sequence written after
+	// every colored part (ne).
+	NoErase bool
+}
+
+// DefaultColors are the colors GNU grep uses when GREP_COLORS is unset.
+var DefaultColors = Colors{
+	SelectedMatch: "01;31",
+	ContextMatch:  "01;31",
+	FileName:      "35",
+	LineNumber:    "32",
+	ByteOffset:    "32",
+	Separator:     "36",
+}
+
+// ParseColors applies a GREP_COLORS specification on top of the default
+// colors. The specification is a ':' separated list of "cap=SGR" entries
+// and boolean capabilities, e.g. "ms=01;32:fn=34:ne". "mt" sets both
+// ms and mc. Unknown capabilities are ignored, as GNU grep does.
+func ParseColors(spec string) Colors {
+	colors := DefaultColors
+
+	for _, entry := range strings.Split(spec, ":") {
+		name, value, _ := strings.Cut(entry, "=")
+		switch name {
+		case "mt":
+			colors.SelectedMatch = value
+			colors.ContextMatch = value
+		case "ms":
+			colors.SelectedMatch = value
+		case "mc":
+			colors.ContextMatch = value
+		case "sl":
+			colors.SelectedLine = value
+		case "cx":
+			colors.ContextLine = value
+		case "fn":
+			colors.FileName = value
+		case "ln":
+			colors.LineNumber = value
+		case "bn":
+			colors.ByteOffset = value
+		case "se":
+			colors.Separator = value
+		case "ne":
+			colors.NoErase = true
+		}
+	}
+
+	return colors
+}
+
+// IsTerminal reports whether f is connected to a terminal that can
+// display colors, used by --color=auto.
+func IsTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writeColored writes s wrapped in the SGR sequence sgr. Without colors,
+// or with an empty sequence, s is written as is.
+func (p *Printer) writeColored(sgr string, s string) {
+	if p.opts.Colors == nil || sgr == "" || s == "" {
+		p.out.WriteString(s)
+		return
+	}
+
+	erase := "\x1b[K"
+	if p.opts.Colors.NoErase {
+		erase = ""
+	}
+
+	p.out.WriteString("\x1b[" + sgr + "m" + erase)
+	p.out.WriteString(s)
+	p.out.WriteString("\x1b[m" + erase)
+}
+
+// writeHighlighted writes the text of a line, coloring the matched spans
+// with matchColor and the rest of the line with lineColor.
+func (p *Printer) writeHighlighted(text string, spans [][2]int, matchColor string, lineColor string) {
+	if p.opts.Colors == nil {
+		p.out.WriteString(text)
+		return
+	}
+
+	pos := 0
+	for _, span := range spans {
+		if span[0] == span[1] {
+			continue
+		}
+		p.writeColored(lineColor, text[pos:span[0]])
+		p.writeColored(matchColor, text[span[0]:span[1]])
+		pos = span[1]
+	}
+	p.writeColored(lineColor, text[pos:])
+}
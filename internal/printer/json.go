@@ -0,0 +1,212 @@
+package printer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// JSONPrinter is the Output of --json. It writes one JSON object per line
+// for every event of the search, following the schema of ripgrep's JSON
+// output:
+//
+//	{"type":"begin","data":{"path":{"text":"a.txt"}}}
+//	{"type":"match","data":{"path":...,"lines":{"text":"..."},"line_number":3,"absolute_offset":10,"submatches":[...]}}
+//	{"type":"context","data":{...}}
+//	{"type":"end","data":{"path":...,"stats":{...}}}
+//	{"type":"summary","data":{"elapsed_total":{...},"stats":{...}}}
+//
+// "begin" and "end" are only written for files with at least one
+// selected line, and the final "summary" is written by Finish.
+type JSONPrinter struct {
+	out *bufio.Writer
+	enc *json.Encoder
+
+	started time.Time
+	begun   bool // whether "begin" was written for the current file
+
+	// Offset of the first NUL byte of the current file, when it is a
+	// binary file with a selected line; nil otherwise
+	binaryOffset *int64
+	total        jsonStats
+}
+
+// NewJSON creates a JSONPrinter writing to w.
+func NewJSON(w io.Writer) *JSONPrinter {
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	return &JSONPrinter{
+		out:     out,
+		enc:     enc,
+		started: time.Now(),
+	}
+}
+
+// jsonEvent is the envelope of every JSON line.
+type jsonEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// jsonText holds a piece of text, as ripgrep's {"text": "..."} objects.
+type jsonText struct {
+	Text string `json:"text"`
+}
+
+type jsonBegin struct {
+	Path jsonText `json:"path"`
+}
+
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonLine struct {
+	Path           jsonText       `json:"path"`
+	Lines          jsonText       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+type jsonStats struct {
+	Searches          int   `json:"searches"`
+	SearchesWithMatch int   `json:"searches_with_match"`
+	BytesSearched     int64 `json:"bytes_searched"`
+	MatchedLines      int   `json:"matched_lines"`
+	Matches           int   `json:"matches"`
+}
+
+type jsonEnd struct {
+	Path         jsonText  `json:"path"`
+	BinaryOffset *int64    `json:"binary_offset"`
+	Stats        jsonStats `json:"stats"`
+}
+
+type jsonDuration struct {
+	Secs  int64  `json:"secs"`
+	Nanos int    `json:"nanos"`
+	Human string `json:"human"`
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration `json:"elapsed_total"`
+	Stats        jsonStats    `json:"stats"`
+}
+
+// BeginFile resets the per-file state; "begin" itself is delayed until
+// the file has a selected or context line.
+func (p *JSONPrinter) BeginFile(file string) {
+	p.begun = false
+	p.binaryOffset = nil
+}
+
+// PrintLine writes a "match" event with the submatches of the line.
+func (p *JSONPrinter) PrintLine(file string, line Line) {
+	p.writeLine("match", file, line)
+}
+
+// PrintContext writes a "context" event.
+func (p *JSONPrinter) PrintContext(file string, line Line) {
+	p.writeLine("context", file, line)
+}
+
+// PrintCount is not part of the JSON schema; counts are reported in the
+// stats of the "end" and "summary" events instead.
+func (p *JSONPrinter) PrintCount(file string, count int) {}
+
+// PrintFileName is not part of the JSON schema; matching files are the
+// ones with a "begin" event.
+func (p *JSONPrinter) PrintFileName(file string) {}
+
+// PrintBinaryMatch writes the "begin" event of a binary file with a
+// selected line. Its lines are not written; like ripgrep, the "end"
+// event gives the offset of the NUL byte that made it binary.
+func (p *JSONPrinter) PrintBinaryMatch(file string, offset int64) {
+	p.begin(file)
+	p.binaryOffset = &offset
+}
+
+// EndFile writes the "end" event of a file that had output, and adds
+// its stats to the summary.
+func (p *JSONPrinter) EndFile(file string, stats Stats) {
+	fileStats := jsonStats{
+		Searches:      1,
+		BytesSearched: stats.BytesSearched,
+		MatchedLines:  stats.SelectedLines,
+		Matches:       stats.Matches,
+	}
+	if stats.SelectedLines > 0 {
+		fileStats.SearchesWithMatch = 1
+	}
+
+	p.total.Searches += fileStats.Searches
+	p.total.SearchesWithMatch += fileStats.SearchesWithMatch
+	p.total.BytesSearched += fileStats.BytesSearched
+	p.total.MatchedLines += fileStats.MatchedLines
+	p.total.Matches += fileStats.Matches
+
+	if p.begun {
+		p.write("end", jsonEnd{Path: jsonText{file}, BinaryOffset: p.binaryOffset, Stats: fileStats})
+		p.begun = false
+	}
+}
+
+// Finish writes the "summary" event and flushes the output.
+func (p *JSONPrinter) Finish() error {
+	elapsed := time.Since(p.started)
+	p.write("summary", jsonSummary{
+		ElapsedTotal: jsonDuration{
+			Secs:  int64(elapsed / time.Second),
+			Nanos: int(elapsed % time.Second),
+			Human: elapsed.String(),
+		},
+		Stats: p.total,
+	})
+	return p.out.Flush()
+}
+
+// writeLine writes a "match" or "context" event, preceded by the
+// "begin" event of the file if this is its first line.
+func (p *JSONPrinter) writeLine(kind string, file string, line Line) {
+	p.begin(file)
+
+	submatches := make([]jsonSubmatch, 0, len(line.Matches))
+	for _, span := range line.Matches {
+		if span[0] == span[1] {
+			continue
+		}
+		submatches = append(submatches, jsonSubmatch{
+			Match: jsonText{line.Text[span[0]:span[1]]},
+			Start: span[0],
+			End:   span[1],
+		})
+	}
+
+	p.write(kind, jsonLine{
+		Path:           jsonText{file},
+		Lines:          jsonText{line.Text + line.Terminator},
+		LineNumber:     line.Number,
+		AbsoluteOffset: line.Offset,
+		Submatches:     submatches,
+	})
+}
+
+// begin writes the "begin" event of the file, unless already written.
+func (p *JSONPrinter) begin(file string) {
+	if !p.begun {
+		p.write("begin", jsonBegin{Path: jsonText{file}})
+		p.begun = true
+	}
+}
+
+// write encodes a single event on its own line. Encoding errors cannot
+// happen for these types, and write errors surface in Finish.
+func (p *JSONPrinter) write(kind string, data any) {
+	p.enc.Encode(jsonEvent{Type: kind, Data: data})
+}
@@ -0,0 +1,272 @@
+package printer
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// Options controls how matching lines are rendered.
+type Options struct {
+	// WithFilename prefixes every output line with the name of the file
+	// it came from, followed by a ':' separator.
+	WithFilename bool
+
+	// LineNumber prefixes every output line with its 1-based line number (-n).
+	LineNumber bool
+
+	// ByteOffset prefixes every output line with the 0-based byte offset
+	// of the start of the line within its file (-b). With OnlyMatching the
+	// offset is the one of the matched text instead.
+	ByteOffset bool
+
+	// OnlyMatching prints every non-empty match of a line on its own
+	// output line instead of the whole line (-o).
+	OnlyMatching bool
+
+	// Context is set when context lines are requested (-A, -B, -C).
+	// Groups of lines that are not adjacent are then separated by "--".
+	Context bool
+
+	// Colors enables ANSI highlighting of matches, file names, line
+	// numbers and separators (--color). nil disables colors.
+	Colors *Colors
+
+	// NullAfterName writes a NUL byte after file names instead of the
+	// ':' / '-' separator or the newline of -l and -L (-Z).
+	NullAfterName bool
+
+	// NullData ends output lines with a NUL byte instead of a newline,
+	// matching NUL terminated input records (-z).
+	NullData bool
+}
+
+// Line is a single line selected by a search, together with its
+// position inside the file it was read from.
+type Line struct {
+	Number int    // 1-based line number
+	Offset int64  // byte offset of the first byte of the line
+	Text   string // line content, without the line terminator
+
+	// Terminator is the terminator that ended the line in the input:
+	// "\n", "\r\n", NUL with -z, or "" for a last line without one.
+	Terminator string
+
+	// Matches holds the [start, end) byte spans of every match within
+	// Text. It is only filled in when the search was asked for spans.
+	Matches [][2]int
+}
+
+// MatchCount returns the number of matches of the line that are
+// reported: empty matches are never printed, so they are not counted.
+func (l Line) MatchCount() int {
+	count := 0
+	for _, span := range l.Matches {
+		if span[0] != span[1] {
+			count++
+		}
+	}
+	return count
+}
+
+// Stats summarizes the search of a single file.
+type Stats struct {
+	SelectedLines int   // number of selected lines
+	Matches       int   // number of non-empty matches on selected lines, when spans were reported
+	BytesSearched int64 // number of bytes read from the file
+}
+
+// Output is the single output layer shared by every search mode
+// (stdin, single file, multiple files and recursive directory search).
+// Search functions report what they found to an Output and never write
+// to stdout themselves. Printer writes grep style text and JSONPrinter
+// writes JSON Lines, both from the same Line and Stats values.
+type Output interface {
+	// BeginFile is called before a file is searched.
+	BeginFile(file string)
+
+	// PrintLine reports a selected line.
+	PrintLine(file string, line Line)
+
+	// PrintContext reports a context line around a selected line.
+	PrintContext(file string, line Line)
+
+	// PrintCount reports the number of selected lines of a file (-c).
+	PrintCount(file string, count int)
+
+	// PrintFileName reports a file name on its own (-l, -L).
+	PrintFileName(file string)
+
+	// PrintBinaryMatch reports that a binary file has a selected line,
+	// in place of its lines. offset is the byte offset of the first NUL
+	// byte, which made the file binary.
+	PrintBinaryMatch(file string, offset int64)
+
+	// EndFile is called once a file has been searched.
+	EndFile(file string, stats Stats)
+
+	// Finish writes any summary and flushes buffered output.
+	Finish() error
+}
+
+// Printer is the plain text Output, formatting lines like GNU grep.
+type Printer struct {
+	out  *bufio.Writer
+	opts Options
+
+	// Position of the last printed line, used to detect gaps between
+	// groups of context
+	printed    bool
+	lastFile   string
+	lastNumber int
+}
+
+// New creates a Printer writing to w with the given options.
+func New(w io.Writer, opts Options) *Printer {
+	return &Printer{
+		out:  bufio.NewWriter(w),
+		opts: opts,
+	}
+}
+
+// PrintLine writes a single matching line, prefixed with its file name,
+// line number and byte offset when the options ask for them, in the
+// form "file:line:offset:text". In only-matching mode every match of
+// the line is written instead, each with its own prefix.
+//
+// Params:
+//   - file: display name of the file the line came from
+//   - line: the selected line and its position
+func (p *Printer) PrintLine(file string, line Line) {
+	p.separate(file, line.Number)
+
+	if !p.opts.OnlyMatching {
+		p.writePrefix(file, line.Number, line.Offset, ':')
+		p.writeHighlighted(line.Text, line.Matches, p.colors().SelectedMatch, p.colors().SelectedLine)
+		p.endLine()
+		return
+	}
+
+	for _, span := range line.Matches {
+		if span[0] == span[1] {
+			// Empty matches have nothing to show
+			continue
+		}
+		p.writePrefix(file, line.Number, line.Offset+int64(span[0]), ':')
+		p.writeColored(p.colors().SelectedMatch, line.Text[span[0]:span[1]])
+		p.endLine()
+	}
+}
+
+// PrintContext writes a context line surrounding a selected line. It is
+// formatted like a selected line but with '-' separators:
+// "file-line-offset-text". Context lines are not shown with -o.
+func (p *Printer) PrintContext(file string, line Line) {
+	if p.opts.OnlyMatching {
+		return
+	}
+
+	p.separate(file, line.Number)
+	p.writePrefix(file, line.Number, line.Offset, '-')
+	p.writeHighlighted(line.Text, line.Matches, p.colors().ContextMatch, p.colors().ContextLine)
+	p.endLine()
+}
+
+// separate writes the "--" group separator when context is enabled and
+// the line about to be printed does not directly follow the last one.
+func (p *Printer) separate(file string, number int) {
+	if p.opts.Context && p.printed && (file != p.lastFile || number != p.lastNumber+1) {
+		p.writeColored(p.colors().Separator, "--")
+		p.endLine()
+	}
+	p.printed = true
+	p.lastFile = file
+	p.lastNumber = number
+}
+
+// writePrefix writes the "file:line:offset:" prefix of an output line,
+// leaving out the parts that are not enabled. sep is ':' for selected
+// lines and '-' for context lines.
+func (p *Printer) writePrefix(file string, number int, offset int64, sep byte) {
+	colors := p.colors()
+	if p.opts.WithFilename {
+		p.writeFileName(file, sep)
+	}
+	if p.opts.LineNumber {
+		p.writeColored(colors.LineNumber, strconv.Itoa(number))
+		p.writeColored(colors.Separator, string(sep))
+	}
+	if p.opts.ByteOffset {
+		p.writeColored(colors.ByteOffset, strconv.FormatInt(offset, 10))
+		p.writeColored(colors.Separator, string(sep))
+	}
+}
+
+// noColors leaves every part of the output uncolored.
+var noColors Colors
+
+// colors returns the configured colors, or an empty set when colors
+// are disabled.
+func (p *Printer) colors() *Colors {
+	if p.opts.Colors == nil {
+		return &noColors
+	}
+	return p.opts.Colors
+}
+
+// PrintCount writes the number of selected lines of a file (-c),
+// prefixed with the file name when the options ask for it.
+func (p *Printer) PrintCount(file string, count int) {
+	if p.opts.WithFilename {
+		p.writeFileName(file, ':')
+	}
+	p.out.WriteString(strconv.Itoa(count))
+	p.out.WriteByte('\n')
+}
+
+// PrintFileName writes the name of a file on its own line (-l, -L).
+// With -Z the name is ended by a NUL byte instead of a newline.
+func (p *Printer) PrintFileName(file string) {
+	p.writeColored(p.colors().FileName, file)
+	if p.opts.NullAfterName {
+		p.out.WriteByte(0)
+	} else {
+		p.out.WriteByte('\n')
+	}
+}
+
+// PrintBinaryMatch writes the "Binary file X matches" message of GNU grep.
+func (p *Printer) PrintBinaryMatch(file string, offset int64) {
+	p.out.WriteString("Binary file " + file + " matches\n")
+}
+
+// writeFileName writes a file name followed by sep, or by a NUL byte
+// with -Z, so names containing ':' or newlines stay unambiguous.
+func (p *Printer) writeFileName(file string, sep byte) {
+	p.writeColored(p.colors().FileName, file)
+	if p.opts.NullAfterName {
+		p.out.WriteByte(0)
+		return
+	}
+	p.writeColored(p.colors().Separator, string(sep))
+}
+
+// endLine ends an output line with a newline, or a NUL byte with -z.
+func (p *Printer) endLine() {
+	if p.opts.NullData {
+		p.out.WriteByte(0)
+		return
+	}
+	p.out.WriteByte('\n')
+}
+
+// BeginFile does nothing: text output has no per-file header.
+func (p *Printer) BeginFile(file string) {}
+
+// EndFile does nothing: text output has no per-file footer.
+func (p *Printer) EndFile(file string, stats Stats) {}
+
+// Finish writes any buffered output to the underlying writer.
+func (p *Printer) Finish() error {
+	return p.out.Flush()
+}